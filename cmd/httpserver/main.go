@@ -1,61 +1,58 @@
 package main
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
-	"go-http/internal/headers"
 	"go-http/internal/request"
 	"go-http/internal/response"
+	"go-http/internal/router"
 	"go-http/internal/server"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
+	"time"
 )
 
 const port = 42069
+const shutdownTimeout = 10 * time.Second
 
 func main() {
-	server, err := server.Serve(port, superCoolHandler)
+	server, err := server.Serve(port, newRouter().Serve)
 	if err != nil {
 		log.Fatalf("Error starting server: %v", err)
 	}
-	defer server.Close()
 	log.Println("Server started on port", port)
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 	<-sigChan
-	log.Println("Server gracefully stopped")
-}
 
-func superCoolHandler(w *response.Writer, req *request.Request) {
-	if strings.HasPrefix(req.RequestLine.RequestTarget, "/httpbin") {
-		proxyHandler(w, req)
-		return
-	}
-	if req.RequestLine.RequestTarget == "/video" {
-		handlerVideo(w, req)
-	}
-	if req.RequestLine.RequestTarget == "/yourproblem" {
-		handler400(w, req)
-		return
-	}
-
-	if req.RequestLine.RequestTarget == "/myproblem" {
-		handler500(w, req)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error during shutdown: %v", err)
 		return
 	}
+	log.Println("Server gracefully stopped")
+}
 
-	handler200(w, req)
-	return
+func newRouter() *router.Router {
+	r := router.New()
+	r.Use(server.LogRequests)
+	r.Handle("/httpbin/{path...}", proxyHandler)
+	r.Handle("GET /video", handlerVideo)
+	r.Handle("/yourproblem", handler400)
+	r.Handle("/myproblem", handler500)
+	r.Handle("/{path...}", handler200)
+	return r
 }
 
 func handler400(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusBadRequest)
 	body := []byte(`<html>
 <head>
 <title>400 Bad Request</title>
@@ -65,14 +62,12 @@ func handler400(w *response.Writer, _ *request.Request) {
 <p>Your request honestly kinda sucked.</p>
 </body>
 </html>`)
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusBadRequest)
+	w.Write(body)
 }
 
 func handler500(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusInternalServerError)
 	body := []byte(`<html>
 <head>
 <title>500 Internal Server Error</title>
@@ -83,14 +78,12 @@ func handler500(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusInternalServerError)
+	w.Write(body)
 }
 
 func handler200(w *response.Writer, _ *request.Request) {
-	w.WriteStatusLine(response.StatusOK)
 	body := []byte(`<html>
 <head>
 <title>200 OK</title>
@@ -101,16 +94,19 @@ func handler200(w *response.Writer, _ *request.Request) {
 </body>
 </html>
 `)
-	h := response.GetDefaultHeaders(len(body))
-	h.Override("Content-Type", "text/html")
-	w.WriteHeaders(h)
-	w.WriteBody(body)
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(response.StatusOK)
+	w.Write(body)
 }
 
 func proxyHandler(w *response.Writer, req *request.Request) {
-	target := strings.TrimPrefix(req.RequestLine.RequestTarget, "/httpbin/")
-	url := "https://httpbin.org/" + target
-	resp, err := http.Get(url)
+	upstream := url.URL{
+		Scheme:   "https",
+		Host:     "httpbin.org",
+		Path:     "/" + req.PathValue("path"),
+		RawQuery: req.URL.RawQuery,
+	}
+	resp, err := http.Get(upstream.String())
 	if err != nil {
 		handler500(w, req)
 		return
@@ -118,12 +114,11 @@ func proxyHandler(w *response.Writer, req *request.Request) {
 
 	defer resp.Body.Close()
 
-	w.WriteStatusLine(response.StatusOK)
-	h := response.GetDefaultHeaders(0)
-	h.Override("Transfer-Encoding", "chunked")
-	h.Remove("Content-Length")
-	h.Set("Trailer", "X-Content-SHA256, X-Content-Length")
-	w.WriteHeaders(h)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Trailer", "X-Content-SHA256, X-Content-Length")
+	// Send the header now so the body streams chunked and can carry
+	// trailers.
+	w.Flush()
 
 	const maxChunkSize = 1024
 	fullBody := make([]byte, 0)
@@ -132,7 +127,7 @@ func proxyHandler(w *response.Writer, req *request.Request) {
 		n, err := resp.Body.Read(buffer)
 		fmt.Println("Read", n, "byte")
 		if n > 0 {
-			_, err := w.WriteChunkedBody(buffer[:n])
+			_, err := w.Write(buffer[:n])
 			fullBody = append(fullBody, buffer[:n]...)
 
 			if err != nil {
@@ -148,29 +143,21 @@ func proxyHandler(w *response.Writer, req *request.Request) {
 			break
 		}
 	}
-	_, err = w.WriteChunkedBodyDone()
 
 	checksum := sha256.Sum256(fullBody)
-	length := len(fullBody)
-	trailerHeaders := headers.NewHeaders()
-	trailerHeaders.Set("X-Content-SHA256", fmt.Sprintf("%x", checksum))
-	trailerHeaders.Set("X-Content-Length", fmt.Sprintf("%d", length))
-	w.WriteTrailers(trailerHeaders)
-
-	if err != nil {
-		fmt.Println("error writing chunked done", err)
-	}
+	w.Trailer().Set("X-Content-SHA256", fmt.Sprintf("%x", checksum))
+	w.Trailer().Set("X-Content-Length", fmt.Sprintf("%d", len(fullBody)))
 }
 
-func handlerVideo(w *response.Writer, _ *request.Request) {
+func handlerVideo(w *response.Writer, req *request.Request) {
 	video, err := os.ReadFile("assets/lol.mp4")
 	if err != nil {
 		fmt.Println("error loading video into memory: ", err)
+		handler500(w, req)
+		return
 	}
 
-	w.WriteStatusLine(response.StatusOK)
-	h := response.GetDefaultHeaders(len(video))
-	h.Override("Content-Type", "video/mp4")
-	w.WriteHeaders(h)
-	w.WriteBody(video)
+	w.Header().Set("Content-Type", "video/mp4")
+	w.Header().SetContentLength(int64(len(video)))
+	w.Write(video)
 }
@@ -35,11 +35,15 @@ func main() {
 		fmt.Println("- Version:", req.RequestLine.HttpVersion)
 
 		fmt.Println("Headers:")
-		for key, val := range req.Headers {
+		for key, val := range req.Headers.All() {
 			fmt.Printf("- %s: %s\n", key, val)
 		}
 
+		body, err := req.BodyBytes()
+		if err != nil {
+			log.Fatalf("error reading body: %v", err.Error())
+		}
 		fmt.Println("Body:")
-		fmt.Println(string(req.Body))
+		fmt.Println(string(body))
 	}
 }
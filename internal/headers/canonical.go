@@ -0,0 +1,53 @@
+package headers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// canonicalExceptions holds names whose conventional spelling does not follow
+// the capitalize-each-word rule, keyed by their lowercase form.
+var canonicalExceptions = map[string]string{
+	"content-md5":            "Content-MD5",
+	"dnt":                    "DNT",
+	"etag":                   "ETag",
+	"te":                     "TE",
+	"www-authenticate":       "WWW-Authenticate",
+	"x-xss-protection":       "X-XSS-Protection",
+	"x-ua-compatible":        "X-UA-Compatible",
+	"x-dns-prefetch-control": "X-DNS-Prefetch-Control",
+}
+
+// CanonicalName returns the conventional spelling of a field name, with the
+// first letter and every letter after a hyphen in uppercase, so
+// "content-length" becomes "Content-Length". A few names such as "ETag" are
+// special cased.
+func CanonicalName(name string) string {
+	lower := strings.ToLower(name)
+	if exception, ok := canonicalExceptions[lower]; ok {
+		return exception
+	}
+	b := []byte(lower)
+	upper := true
+	for i, c := range b {
+		if upper && 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+		upper = c == '-'
+	}
+	return string(b)
+}
+
+// ValidateField checks that a field can be written out safely: the name must
+// be a token and the value may not contain control characters such as CR or
+// LF, which would let it end the field line early and inject fields or a body
+// of its own.
+func ValidateField(name, value string) error {
+	if err := validFieldName(name); err != nil {
+		return err
+	}
+	if err := validFieldValue(value); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -3,50 +3,130 @@ package headers
 import (
 	"bytes"
 	"fmt"
+	"iter"
+	"slices"
 	"strings"
 )
 
 const crlf = "\r\n"
 
-type Headers map[string]string
+// Field is a single field line. Name keeps the casing it was received or
+// set with.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Headers is an ordered list of header or trailer fields. Names are matched
+// case-insensitively and may repeat, as Set-Cookie does; the fields are
+// serialized in the order they were added. The zero value is empty and ready
+// to use.
+type Headers struct {
+	// PreserveCase makes All report field names as they were received or
+	// set instead of in canonical form. It lets a response carry a name
+	// whose casing a picky client depends on.
+	PreserveCase bool
+	// UnfoldObsFold makes Parse accept obsolete line folding, a field line
+	// starting with whitespace that continues the previous one, and join it
+	// to that field's value with a space. Otherwise folding is rejected, as
+	// RFC 9112 5.2 recommends for servers.
+	UnfoldObsFold bool
+
+	fields []Field
+}
 
-func NewHeaders() Headers {
-	return make(Headers)
+func NewHeaders() *Headers {
+	return &Headers{}
 }
 
-func (h Headers) Set(key, value string) {
-	key = strings.ToLower(key)
-	v, ok := h[key]
-	if ok {
-		value = strings.Join([]string{
-			v,
-			value,
-		}, ", ")
+// Get returns the first value of key. Use Values for fields that may be
+// sent more than once.
+func (h *Headers) Get(key string) (string, bool) {
+	for _, f := range h.fields {
+		if strings.EqualFold(f.Name, key) {
+			return f.Value, true
+		}
 	}
-	h[key] = value
+	return "", false
 }
 
-func (h Headers) Override(key, value string) {
-	key = strings.ToLower(key)
-	h[key] = value
+// Values returns every value of key in order.
+func (h *Headers) Values(key string) []string {
+	var values []string
+	for _, f := range h.fields {
+		if strings.EqualFold(f.Name, key) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
 }
 
-func (h Headers) Remove(key string) {
-	key = strings.ToLower(key)
-	delete(h, key)
+// Set replaces all values of key with value. The field keeps the position of
+// its first occurrence, or is appended if it is new.
+func (h *Headers) Set(key, value string) {
+	for i, f := range h.fields {
+		if strings.EqualFold(f.Name, key) {
+			h.fields[i] = Field{Name: key, Value: value}
+			h.fields = append(h.fields[:i+1], deleteFields(h.fields[i+1:], key)...)
+			return
+		}
+	}
+	h.Add(key, value)
 }
 
-func (h Headers) SetHeader(key string, val string) {
-	h[key] = val
+// Add appends a field, keeping any existing values of key.
+func (h *Headers) Add(key, value string) {
+	h.fields = append(h.fields, Field{Name: key, Value: value})
 }
 
-func (h Headers) Get(key string) (string, bool) {
-	val, ok := h[strings.ToLower(key)]
-	return val, ok
+// Del removes every value of key.
+func (h *Headers) Del(key string) {
+	h.fields = deleteFields(h.fields, key)
 }
 
-func (h Headers) Parse(data []byte) (n int, done bool, err error) {
+func deleteFields(fields []Field, key string) []Field {
+	return slices.DeleteFunc(fields, func(f Field) bool {
+		return strings.EqualFold(f.Name, key)
+	})
+}
+
+// Len returns the number of fields.
+func (h *Headers) Len() int {
+	return len(h.fields)
+}
 
+// Clone returns a copy that can be changed independently.
+func (h *Headers) Clone() *Headers {
+	return &Headers{
+		PreserveCase:  h.PreserveCase,
+		UnfoldObsFold: h.UnfoldObsFold,
+		fields:        slices.Clone(h.fields),
+	}
+}
+
+// All iterates over the fields in order. Names are in canonical form unless
+// PreserveCase is set.
+func (h *Headers) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range h.fields {
+			name := f.Name
+			if !h.PreserveCase {
+				name = CanonicalName(name)
+			}
+			if !yield(name, f.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Parse parses one field line from data and appends it. It returns done once
+// it reaches the empty line ending the section.
+func (h *Headers) Parse(data []byte) (n int, done bool, err error) {
+	// A bare LF is an error as soon as it is seen, not once a CRLF follows.
+	if lf := bytes.IndexByte(data, '\n'); lf == 0 || (lf > 0 && data[lf-1] != '\r') {
+		return 0, false, fmt.Errorf("invalid field line format: bare LF")
+	}
 	idx := bytes.Index(data, []byte(crlf))
 
 	if idx == -1 {
@@ -56,45 +136,81 @@ func (h Headers) Parse(data []byte) (n int, done bool, err error) {
 		return len(crlf), true, nil
 	}
 
-	fieldName, fieldValue, err := fieldLineFromString(string(data[:idx]))
+	line := string(data[:idx])
+	if line[0] == ' ' || line[0] == '\t' {
+		if err := h.unfold(line); err != nil {
+			return 0, false, err
+		}
+		return idx + 2, false, nil
+	}
+
+	fieldName, fieldValue, err := fieldLineFromString(line)
 	if err != nil {
 		return 0, false, err
 	}
 
-	value, ok := h[fieldName]
-	if ok {
-		h[fieldName] = value + ", " + fieldValue
-	} else {
-		h[fieldName] = fieldValue
-	}
+	h.Add(fieldName, fieldValue)
 
 	return idx + 2, false, nil
 }
 
-func fieldLineFromString(str string) (fieldName string, fieldValue string, err error) {
-	trimmed := strings.TrimSpace(str)
+// unfold appends an obs-fold continuation line to the last field parsed.
+func (h *Headers) unfold(line string) error {
+	if !h.UnfoldObsFold {
+		return fmt.Errorf("obsolete line folding")
+	}
+	if len(h.fields) == 0 {
+		return fmt.Errorf("obsolete line folding before the first field")
+	}
+	if err := validFieldValue(line); err != nil {
+		return err
+	}
+	if continuation := strings.Trim(line, " \t"); continuation != "" {
+		last := &h.fields[len(h.fields)-1]
+		last.Value = strings.TrimLeft(last.Value+" "+continuation, " ")
+	}
+	return nil
+}
 
-	fieldName, fieldValue, found := strings.Cut(trimmed, ":")
+func fieldLineFromString(str string) (fieldName string, fieldValue string, err error) {
+	fieldName, fieldValue, found := strings.Cut(str, ":")
 
-	if len(fieldName) != len(strings.TrimSpace(fieldName)) {
+	if len(fieldName) != len(strings.Trim(fieldName, " \t")) {
 		return "", "", fmt.Errorf("invalid field line format: space between field name and semicolon.")
 	}
 	if !found {
 		return "", "", fmt.Errorf("invalid field line format")
 	}
 
-	fieldValue = strings.TrimSpace(fieldValue)
-
-	fieldName = strings.ToLower(fieldName)
+	// OWS around the value is only ever SP or HTAB.
+	fieldValue = strings.Trim(fieldValue, " \t")
 
 	err = validFieldName(fieldName)
 	if err != nil {
 		return "", "", err
 	}
+	if err := validFieldValue(fieldValue); err != nil {
+		return "", "", err
+	}
 
 	return fieldName, fieldValue, nil
 }
 
+// validFieldValue allows visible characters, obs-text, SP and HTAB, RFC 9110
+// 5.5. A lone CR or LF in particular may be treated as a line break by other
+// parsers in the chain, so it is never accepted.
+func validFieldValue(value string) error {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			if c == '\r' || c == '\n' {
+				return fmt.Errorf("invalid field line format: bare CR or LF")
+			}
+			return fmt.Errorf("invalid character %q in field value", c)
+		}
+	}
+	return nil
+}
+
 func validFieldName(fieldName string) error {
 	if len(fieldName) == 0 {
 		return fmt.Errorf("empty field name")
@@ -111,3 +227,11 @@ func validFieldName(fieldName string) error {
 	}
 	return nil
 }
+
+// HasToken reports whether the comma-separated values of key contain token,
+// compared case-insensitively. Used for fields like Connection.
+func (h *Headers) HasToken(key, token string) bool {
+	return slices.ContainsFunc(h.List(key), func(element string) bool {
+		return strings.EqualFold(element, token)
+	})
+}
@@ -2,6 +2,7 @@ package headers
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,28 +15,29 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err := headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:8000", headers["host"])
+	assert.Equal(t, []string{"localhost:8000"}, headers.Values("host"))
 	assert.Equal(t, 22, n)
 	assert.False(t, done)
 
 	// Test: Valid single header with extra whitespace
 	headers = NewHeaders()
-	data = []byte("       Host: localhost:6767                           \r\n\r\n")
+	data = []byte("Host:        localhost:6767                           \r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:6767", headers["host"])
+	assert.Equal(t, []string{"localhost:6767"}, headers.Values("host"))
 	assert.Equal(t, 56, n)
 	assert.False(t, done)
 
 	// Test: Valid 2 headers with existing headers
-	headers = map[string]string{"host": "localhost:6767"}
+	headers = NewHeaders()
+	headers.Add("Host", "localhost:6767")
 	data = []byte("User-Agent: curl/7.81.0\r\nAccept: */*\r\n\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:6767", headers["host"])
-	assert.Equal(t, "curl/7.81.0", headers["user-agent"])
+	assert.Equal(t, []string{"localhost:6767"}, headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, headers.Values("user-agent"))
 	assert.Equal(t, 25, n)
 	assert.False(t, done)
 
@@ -45,7 +47,7 @@ func TestHeadersParse(t *testing.T) {
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Empty(t, headers)
+	assert.Equal(t, 0, headers.Len())
 	assert.Equal(t, 2, n)
 	assert.True(t, done)
 
@@ -73,12 +75,236 @@ func TestHeadersParse(t *testing.T) {
 	assert.False(t, done)
 
 	// Test: Multiple values for a single header key
-	headers = map[string]string{"host": "localhost:6767"}
+	headers = NewHeaders()
+	headers.Add("Host", "localhost:6767")
 	data = []byte("Host: localhost:6969\r\n")
 	n, done, err = headers.Parse(data)
 	require.NoError(t, err)
 	require.NotNil(t, headers)
-	assert.Equal(t, "localhost:6767, localhost:6969", headers["host"])
+	assert.Equal(t, []string{"localhost:6767", "localhost:6969"}, headers.Values("host"))
 	assert.Equal(t, 22, n)
 	assert.False(t, done)
 }
+
+func TestParseMediaType(t *testing.T) {
+	// Test: Type with token and quoted parameters
+	mediaType, params, err := ParseMediaType(`Multipart/Form-Data; Boundary="a b\"c"; charset=UTF-8`)
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+	assert.Equal(t, map[string]string{"boundary": `a b"c`, "charset": "UTF-8"}, params)
+
+	// Test: No parameters
+	mediaType, params, err = ParseMediaType("text/plain")
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", mediaType)
+	assert.Empty(t, params)
+
+	// Test: Content-Disposition
+	disposition, params, err := ParseContentDisposition(`form-data; name="file"; filename="a.txt"`)
+	require.NoError(t, err)
+	assert.Equal(t, "form-data", disposition)
+	assert.Equal(t, "file", params["name"])
+	assert.Equal(t, "a.txt", params["filename"])
+
+	// Test: Invalid values
+	for _, value := range []string{"", "text", "text/", "text/plain; charset", `text/plain; a="open`, "text/plain; a=1; a=2", "text/plain; a=b c"} {
+		_, _, err := ParseMediaType(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestHeadersFields(t *testing.T) {
+	h := NewHeaders()
+	h.Add("Set-Cookie", "a=1")
+	h.Add("Content-Type", "text/plain")
+	h.Add("set-cookie", "b=2")
+
+	// Test: Repeated fields keep every value in order
+	assert.Equal(t, []string{"a=1", "b=2"}, h.Values("SET-COOKIE"))
+	value, ok := h.Get("Set-Cookie")
+	assert.True(t, ok)
+	assert.Equal(t, "a=1", value)
+	_, ok = h.Get("Missing")
+	assert.False(t, ok)
+
+	// Test: Serialization follows insertion order
+	var names []string
+	for name := range h.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "Content-Type", "Set-Cookie"}, names)
+
+	// Test: Original casing on request
+	h.PreserveCase = true
+	names = nil
+	for name := range h.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "Content-Type", "set-cookie"}, names)
+
+	// Test: Clone is independent
+	clone := h.Clone()
+	clone.Add("X-Extra", "1")
+	assert.Equal(t, 3, h.Len())
+	assert.Equal(t, 4, clone.Len())
+
+	// Test: Clone keeps the parsing options
+	h.UnfoldObsFold = true
+	options := h.Clone()
+	assert.True(t, options.PreserveCase)
+	assert.True(t, options.UnfoldObsFold)
+	h.UnfoldObsFold = false
+
+	// Test: Set replaces all values in place of the first
+	h.Set("Set-Cookie", "c=3")
+	assert.Equal(t, []string{"c=3"}, h.Values("set-cookie"))
+	names = nil
+	for name := range h.All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"Set-Cookie", "Content-Type"}, names)
+
+	// Test: Del removes every value
+	h.Del("set-cookie")
+	assert.Nil(t, h.Values("Set-Cookie"))
+	assert.Equal(t, 1, h.Len())
+	assert.Equal(t, 4, clone.Len())
+
+	// Test: Zero value is usable
+	var zero Headers
+	zero.Set("A", "1")
+	assert.Equal(t, []string{"1"}, zero.Values("a"))
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := map[string]string{
+		"content-length":   "Content-Length",
+		"CONTENT-TYPE":     "Content-Type",
+		"x-request-id":     "X-Request-Id",
+		"etag":             "ETag",
+		"WWW-AUTHENTICATE": "WWW-Authenticate",
+		"te":               "TE",
+		"a--b":             "A--B",
+		"x-1st":            "X-1st",
+	}
+	for name, want := range tests {
+		assert.Equal(t, want, CanonicalName(name), name)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	// Test: Quoted commas and empty elements
+	elements, err := ParseList(` a, "b, c" ,, d;x="1,2", "e\"," `)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", `"b, c"`, `d;x="1,2"`, `"e\","`}, elements)
+
+	// Test: Unterminated quote
+	_, err = ParseList(`a, "b`)
+	assert.Error(t, err)
+
+	// Test: Repeated fields combine into one list
+	h := NewHeaders()
+	h.Add("Connection", "keep-alive, Upgrade")
+	h.Add("Connection", "close")
+	assert.Equal(t, []string{"keep-alive", "Upgrade", "close"}, h.List("connection"))
+	assert.True(t, h.HasToken("Connection", "upgrade"))
+	assert.True(t, h.HasToken("Connection", "CLOSE"))
+	assert.False(t, h.HasToken("Connection", "keep"))
+}
+
+func TestParseQualityList(t *testing.T) {
+	list, err := ParseQualityList("text/html;level=1;q=0.5, application/json, */*;q=0, text/plain;q=0.500, image/png;q=1.0")
+	require.NoError(t, err)
+	assert.Equal(t, []Weighted{
+		{Value: "application/json", Params: map[string]string{}, Q: 1},
+		{Value: "image/png", Params: map[string]string{}, Q: 1},
+		{Value: "text/html", Params: map[string]string{"level": "1"}, Q: 0.5},
+		{Value: "text/plain", Params: map[string]string{}, Q: 0.5},
+		{Value: "*/*", Params: map[string]string{}, Q: 0},
+	}, list)
+	assert.Equal(t, "application/json, image/png, text/html; level=1; q=0.5, text/plain; q=0.5, */*; q=0", FormatQualityList(list))
+
+	// Test: Invalid weights
+	for _, value := range []string{"gzip;q=1.5", "gzip;q=2", "gzip;q=0.1234", "gzip;q=-1", "gzip;q=1.001", "gzip;q=x"} {
+		_, err := ParseQualityList(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestFormatMediaType(t *testing.T) {
+	assert.Equal(t, "text/plain", FormatMediaType("text/plain", nil))
+	assert.Equal(t, `multipart/form-data; boundary="a b\"c"; charset=utf-8`,
+		FormatMediaType("multipart/form-data", map[string]string{"charset": "utf-8", "boundary": `a b"c`}))
+
+	// Test: Round trip through ParseMediaType
+	mediaType, params, err := ParseMediaType(FormatMediaType("text/html", map[string]string{"x": "", "y": "a;b"}))
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", mediaType)
+	assert.Equal(t, map[string]string{"x": "", "y": "a;b"}, params)
+}
+
+func TestTypedAccessors(t *testing.T) {
+	h := NewHeaders()
+
+	// Test: Absent fields
+	_, err := h.ContentLength()
+	assert.ErrorIs(t, err, ErrMissing)
+	_, _, err = h.ContentType()
+	assert.ErrorIs(t, err, ErrMissing)
+	_, err = h.Date()
+	assert.ErrorIs(t, err, ErrMissing)
+	_, err = h.Host()
+	assert.ErrorIs(t, err, ErrMissing)
+
+	// Test: Setters and getters round trip
+	h.SetContentLength(1234)
+	n, err := h.ContentLength()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1234), n)
+
+	h.SetContentType("text/html", map[string]string{"charset": "utf-8"})
+	mediaType, params, err := h.ContentType()
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", mediaType)
+	assert.Equal(t, "utf-8", params["charset"])
+
+	modified := time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC)
+	h.SetLastModified(modified)
+	value, _ := h.Get("Last-Modified")
+	assert.Equal(t, "Thu, 29 Feb 2024 12:00:00 GMT", value)
+	got, err := h.LastModified()
+	require.NoError(t, err)
+	assert.True(t, modified.Equal(got))
+
+	h.Set("Date", "Sunday, 06-Nov-94 08:49:37 GMT")
+	got, err = h.Date()
+	require.NoError(t, err)
+	assert.Equal(t, 1994, got.Year())
+
+	h.SetHost("example.com:8080")
+	host, err := h.Host()
+	require.NoError(t, err)
+	assert.Equal(t, "example.com:8080", host)
+
+	// Test: Invalid or repeated values
+	for _, value := range []string{"", "-1", "+5", "5, 5", "0x10", "99999999999999999999", " 5"} {
+		h.Set("Content-Length", value)
+		_, err := h.ContentLength()
+		assert.Error(t, err, value)
+	}
+	h.Set("Content-Length", "5")
+	h.Add("Content-Length", "5")
+	_, err = h.ContentLength()
+	assert.Error(t, err)
+
+	h.Add("Host", "other.com")
+	_, err = h.Host()
+	assert.Error(t, err)
+	h.Set("Host", "a.com, b.com")
+	_, err = h.Host()
+	assert.Error(t, err)
+
+	h.Set("Date", "yesterday")
+	_, err = h.Date()
+	assert.Error(t, err)
+}
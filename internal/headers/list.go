@@ -0,0 +1,160 @@
+package headers
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseList splits a comma-separated list, RFC 9110 5.6.1, leaving commas
+// inside quoted strings alone. Elements are trimmed but otherwise returned as
+// sent, quotes included; empty elements are dropped.
+func ParseList(value string) ([]string, error) {
+	var elements []string
+	start, quoted := 0, false
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == ',':
+			elements = appendElement(elements, value[start:i])
+			start = i + 1
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quoted-string in %q", value)
+	}
+	return appendElement(elements, value[start:]), nil
+}
+
+func appendElement(elements []string, element string) []string {
+	if element = strings.Trim(element, " \t"); element != "" {
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+// List returns the elements of every value of key as one list, the way a
+// field sent several times is meant to be combined. Values that don't parse
+// are skipped.
+func (h *Headers) List(key string) []string {
+	var elements []string
+	for _, val := range h.Values(key) {
+		if parsed, err := ParseList(val); err == nil {
+			elements = append(elements, parsed...)
+		}
+	}
+	return elements
+}
+
+// Weighted is an element of a list with quality values, such as Accept or
+// Accept-Encoding.
+type Weighted struct {
+	// Value is the lowercased element without its parameters, for example
+	// "text/html" or "gzip".
+	Value string
+	// Params holds the parameters other than q.
+	Params map[string]string
+	// Q is the weight between 0 and 1, 1 if none was sent.
+	Q float64
+}
+
+// ParseQualityList parses a list like "text/html, application/*;q=0.8"
+// and returns its elements ordered by descending weight, keeping the sent
+// order among equal weights. Elements with q=0 are kept, since they
+// explicitly rule a value out.
+func ParseQualityList(value string) ([]Weighted, error) {
+	elements, err := ParseList(value)
+	if err != nil {
+		return nil, err
+	}
+	weighted := make([]Weighted, 0, len(elements))
+	for _, element := range elements {
+		base, params, err := parseWithParams(element)
+		if err != nil {
+			return nil, err
+		}
+		w := Weighted{Value: base, Params: params, Q: 1}
+		if q, ok := params["q"]; ok {
+			if w.Q, err = parseQValue(q); err != nil {
+				return nil, fmt.Errorf("%v in %q", err, element)
+			}
+			delete(params, "q")
+		}
+		weighted = append(weighted, w)
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].Q > weighted[j].Q
+	})
+	return weighted, nil
+}
+
+// parseQValue parses a weight, RFC 9110 12.4.2: "0" or "1" with up to three
+// decimals, not above 1.
+func parseQValue(s string) (float64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	digits := "0123456789"
+	if whole == "1" {
+		digits = "0"
+	}
+	if (whole != "0" && whole != "1") || len(frac) > 3 || strings.Trim(frac, digits) != "" {
+		return 0, fmt.Errorf("invalid quality value %q", s)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// FormatQualityList is the inverse of ParseQualityList. Weights of 1 are
+// left implicit.
+func FormatQualityList(list []Weighted) string {
+	elements := make([]string, 0, len(list))
+	for _, w := range list {
+		element := FormatMediaType(w.Value, w.Params)
+		if w.Q != 1 {
+			element += "; q=" + strconv.FormatFloat(w.Q, 'f', -1, 64)
+		}
+		elements = append(elements, element)
+	}
+	return strings.Join(elements, ", ")
+}
+
+// FormatMediaType serializes a value with parameters, such as a media type
+// or a disposition, quoting parameter values that are not tokens. Parameters
+// are written in name order so the output is stable.
+func FormatMediaType(value string, params map[string]string) string {
+	var b strings.Builder
+	b.WriteString(value)
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		b.WriteString("; ")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(quoteIfNeeded(params[name]))
+	}
+	return b.String()
+}
+
+// quoteIfNeeded returns s as is if it is a token, otherwise as a
+// quoted-string.
+func quoteIfNeeded(s string) string {
+	if validFieldName(s) == nil {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
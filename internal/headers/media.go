@@ -0,0 +1,97 @@
+package headers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseMediaType parses a Content-Type style value such as
+// `multipart/form-data; boundary="abc"`. The media type and parameter names
+// are lowercased; parameter values are unquoted but keep their case.
+func ParseMediaType(value string) (mediaType string, params map[string]string, err error) {
+	mediaType, params, err = parseWithParams(value)
+	if err != nil {
+		return "", nil, err
+	}
+	typ, subtype, found := strings.Cut(mediaType, "/")
+	if !found || validFieldName(typ) != nil || validFieldName(subtype) != nil {
+		return "", nil, fmt.Errorf("invalid media type %q", mediaType)
+	}
+	return mediaType, params, nil
+}
+
+// ParseContentDisposition parses a Content-Disposition value such as
+// `form-data; name="file"; filename="a.txt"`, RFC 6266.
+func ParseContentDisposition(value string) (disposition string, params map[string]string, err error) {
+	disposition, params, err = parseWithParams(value)
+	if err != nil {
+		return "", nil, err
+	}
+	if validFieldName(disposition) != nil {
+		return "", nil, fmt.Errorf("invalid disposition type %q", disposition)
+	}
+	return disposition, params, nil
+}
+
+// parseWithParams splits `value *( OWS ";" OWS name=value )`, where each
+// parameter value is a token or a quoted-string.
+func parseWithParams(value string) (string, map[string]string, error) {
+	base, rest, _ := strings.Cut(value, ";")
+	base = strings.ToLower(strings.TrimSpace(base))
+	params := make(map[string]string)
+	for {
+		rest = strings.TrimLeft(rest, " \t;")
+		if rest == "" {
+			return base, params, nil
+		}
+		name, after, found := strings.Cut(rest, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !found || validFieldName(name) != nil {
+			return "", nil, fmt.Errorf("invalid parameter in %q", value)
+		}
+		val, after, err := parseParamValue(after)
+		if err != nil {
+			return "", nil, fmt.Errorf("%v in %q", err, value)
+		}
+		if _, dup := params[name]; dup {
+			return "", nil, fmt.Errorf("duplicate parameter %q in %q", name, value)
+		}
+		params[name] = val
+		rest = strings.TrimLeft(after, " \t")
+		if rest != "" && rest[0] != ';' {
+			return "", nil, fmt.Errorf("unexpected %q in %q", rest, value)
+		}
+	}
+}
+
+// parseParamValue reads a token or quoted-string from the front of s and
+// returns it along with the rest of s.
+func parseParamValue(s string) (value, rest string, err error) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, "; \t")
+		if end == -1 {
+			end = len(s)
+		}
+		if validFieldName(s[:end]) != nil {
+			return "", "", fmt.Errorf("invalid parameter value %q", s[:end])
+		}
+		return s[:end], s[end:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", "", fmt.Errorf("unterminated quoted-string")
+			}
+			i++
+			b.WriteByte(s[i])
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted-string")
+}
@@ -0,0 +1,574 @@
+// Package sfv parses and serializes Structured Field Values, RFC 8941.
+//
+// Bare item values are represented as int64 (Integer), float64 (Decimal),
+// string (String), Token, []byte (Byte Sequence) and bool (Boolean).
+package sfv
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Token is a bare item of the Token type, as opposed to a String.
+type Token string
+
+// Param is a single parameter of an item or inner list.
+type Param struct {
+	Key   string
+	Value any
+}
+
+// Params are ordered parameters.
+type Params []Param
+
+// Get returns the value of key.
+func (p Params) Get(key string) (any, bool) {
+	for _, param := range p {
+		if param.Key == key {
+			return param.Value, true
+		}
+	}
+	return nil, false
+}
+
+// set overwrites an existing key in place or appends a new one.
+func (p Params) set(key string, value any) Params {
+	for i := range p {
+		if p[i].Key == key {
+			p[i].Value = value
+			return p
+		}
+	}
+	return append(p, Param{Key: key, Value: value})
+}
+
+// Item is a bare item with parameters.
+type Item struct {
+	Value  any
+	Params Params
+}
+
+// InnerList is a parenthesized list of items with parameters.
+type InnerList struct {
+	Items  []Item
+	Params Params
+}
+
+// Member is a member of a List or Dictionary: an Item or an InnerList.
+type Member interface {
+	member()
+}
+
+func (Item) member()      {}
+func (InnerList) member() {}
+
+// List is a top-level List field.
+type List []Member
+
+// DictMember is a single key of a Dictionary.
+type DictMember struct {
+	Key   string
+	Value Member
+}
+
+// Dictionary is an ordered Dictionary field.
+type Dictionary []DictMember
+
+// Get returns the member for key.
+func (d Dictionary) Get(key string) (Member, bool) {
+	for _, m := range d {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	return nil, false
+}
+
+const (
+	maxInteger       = 999_999_999_999_999
+	maxDecimalDigits = 12
+)
+
+// ParseItem parses an Item field such as `"foo"; a=1`.
+func ParseItem(s string) (Item, error) {
+	p := &parser{s: strings.Trim(s, " ")}
+	item, err := p.item()
+	if err != nil {
+		return Item{}, err
+	}
+	return item, p.end()
+}
+
+// ParseList parses a List field such as `a, (b c);x, "d"`.
+func ParseList(s string) (List, error) {
+	p := &parser{s: strings.Trim(s, " ")}
+	list := List{}
+	for !p.done() {
+		m, err := p.member()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+		if err := p.nextMember(); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// ParseDictionary parses a Dictionary field such as `a=1, b, c=(x y)`. A key
+// without a value is the Boolean true.
+func ParseDictionary(s string) (Dictionary, error) {
+	p := &parser{s: strings.Trim(s, " ")}
+	dict := Dictionary{}
+	for !p.done() {
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var m Member
+		if p.consume('=') {
+			if m, err = p.member(); err != nil {
+				return nil, err
+			}
+		} else {
+			params, err := p.params()
+			if err != nil {
+				return nil, err
+			}
+			m = Item{Value: true, Params: params}
+		}
+		dict = dict.set(key, m)
+		if err := p.nextMember(); err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+// set overwrites an existing key in place, as RFC 8941 4.2.2 requires, or
+// appends a new one.
+func (d Dictionary) set(key string, m Member) Dictionary {
+	for i := range d {
+		if d[i].Key == key {
+			d[i].Value = m
+			return d
+		}
+	}
+	return append(d, DictMember{Key: key, Value: m})
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *parser) consume(c byte) bool {
+	if p.peek() == c && !p.done() {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) != -1 {
+		p.i++
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("sfv: %s at offset %d of %q", fmt.Sprintf(format, args...), p.i, p.s)
+}
+
+func (p *parser) end() error {
+	if !p.done() {
+		return p.errorf("unexpected trailing characters")
+	}
+	return nil
+}
+
+// nextMember consumes the separator after a list or dictionary member.
+func (p *parser) nextMember() error {
+	p.skip(" \t")
+	if p.done() {
+		return nil
+	}
+	if !p.consume(',') {
+		return p.errorf("expected ','")
+	}
+	p.skip(" \t")
+	if p.done() {
+		return p.errorf("trailing ','")
+	}
+	return nil
+}
+
+func (p *parser) member() (Member, error) {
+	if p.peek() == '(' {
+		return p.innerList()
+	}
+	return p.item()
+}
+
+func (p *parser) innerList() (InnerList, error) {
+	p.consume('(')
+	list := InnerList{Items: []Item{}}
+	for !p.done() {
+		p.skip(" ")
+		if p.consume(')') {
+			params, err := p.params()
+			if err != nil {
+				return InnerList{}, err
+			}
+			list.Params = params
+			return list, nil
+		}
+		item, err := p.item()
+		if err != nil {
+			return InnerList{}, err
+		}
+		list.Items = append(list.Items, item)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return InnerList{}, p.errorf("expected ' ' or ')' in inner list")
+		}
+	}
+	return InnerList{}, p.errorf("unterminated inner list")
+}
+
+func (p *parser) item() (Item, error) {
+	value, err := p.bareItem()
+	if err != nil {
+		return Item{}, err
+	}
+	params, err := p.params()
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: value, Params: params}, nil
+}
+
+func (p *parser) params() (Params, error) {
+	var params Params
+	for p.consume(';') {
+		p.skip(" ")
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		var value any = true
+		if p.consume('=') {
+			if value, err = p.bareItem(); err != nil {
+				return nil, err
+			}
+		}
+		params = params.set(key, value)
+	}
+	return params, nil
+}
+
+func (p *parser) key() (string, error) {
+	start := p.i
+	if c := p.peek(); !isLCAlpha(c) && c != '*' {
+		return "", p.errorf("invalid key")
+	}
+	for !p.done() && isKeyChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i], nil
+}
+
+func (p *parser) bareItem() (any, error) {
+	switch c := p.peek(); {
+	case c == '-' || isDigit(c):
+		return p.number()
+	case c == '"':
+		return p.string()
+	case c == '*' || isAlpha(c):
+		return p.token(), nil
+	case c == ':':
+		return p.byteSequence()
+	case c == '?':
+		return p.boolean()
+	}
+	return nil, p.errorf("invalid bare item")
+}
+
+func (p *parser) number() (any, error) {
+	start := p.i
+	p.consume('-')
+	digitsStart := p.i
+	for !p.done() && isDigit(p.s[p.i]) {
+		p.i++
+	}
+	intDigits := p.i - digitsStart
+	if intDigits == 0 {
+		return nil, p.errorf("expected digit")
+	}
+	if !p.consume('.') {
+		if intDigits > 15 {
+			return nil, p.errorf("integer too long")
+		}
+		return strconv.ParseInt(p.s[start:p.i], 10, 64)
+	}
+	if intDigits > maxDecimalDigits {
+		return nil, p.errorf("decimal too long")
+	}
+	fracStart := p.i
+	for !p.done() && isDigit(p.s[p.i]) {
+		p.i++
+	}
+	if frac := p.i - fracStart; frac == 0 || frac > 3 {
+		return nil, p.errorf("decimal needs 1 to 3 fractional digits")
+	}
+	return strconv.ParseFloat(p.s[start:p.i], 64)
+}
+
+func (p *parser) string() (string, error) {
+	p.consume('"')
+	var b strings.Builder
+	for !p.done() {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == '\\':
+			if next := p.peek(); next != '"' && next != '\\' {
+				return "", p.errorf("invalid escape in string")
+			}
+			b.WriteByte(p.s[p.i])
+			p.i++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid character in string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) token() Token {
+	start := p.i
+	p.i++
+	for !p.done() && isTokenChar(p.s[p.i]) {
+		p.i++
+	}
+	return Token(p.s[start:p.i])
+}
+
+func (p *parser) byteSequence() ([]byte, error) {
+	p.consume(':')
+	end := strings.IndexByte(p.s[p.i:], ':')
+	if end == -1 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	data, err := base64.StdEncoding.DecodeString(p.s[p.i : p.i+end])
+	if err != nil {
+		return nil, p.errorf("invalid base64")
+	}
+	p.i += end + 1
+	return data, nil
+}
+
+func (p *parser) boolean() (bool, error) {
+	p.consume('?')
+	switch {
+	case p.consume('1'):
+		return true, nil
+	case p.consume('0'):
+		return false, nil
+	}
+	return false, p.errorf("invalid boolean")
+}
+
+// SerializeItem serializes an Item field.
+func SerializeItem(item Item) (string, error) {
+	var b strings.Builder
+	if err := writeItem(&b, item); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// SerializeList serializes a List field.
+func SerializeList(list List) (string, error) {
+	var b strings.Builder
+	for i, m := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := writeMember(&b, m); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// SerializeDictionary serializes a Dictionary field. Members that are the
+// Boolean true are written as a bare key.
+func SerializeDictionary(dict Dictionary) (string, error) {
+	var b strings.Builder
+	for i, m := range dict {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := writeKey(&b, m.Key); err != nil {
+			return "", err
+		}
+		if item, ok := m.Value.(Item); ok && item.Value == true {
+			if err := writeParams(&b, item.Params); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte('=')
+		if err := writeMember(&b, m.Value); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+func writeMember(b *strings.Builder, m Member) error {
+	switch m := m.(type) {
+	case Item:
+		return writeItem(b, m)
+	case InnerList:
+		b.WriteByte('(')
+		for i, item := range m.Items {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if err := writeItem(b, item); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+		return writeParams(b, m.Params)
+	}
+	return fmt.Errorf("sfv: invalid member type %T", m)
+}
+
+func writeItem(b *strings.Builder, item Item) error {
+	if err := writeBareItem(b, item.Value); err != nil {
+		return err
+	}
+	return writeParams(b, item.Params)
+}
+
+func writeParams(b *strings.Builder, params Params) error {
+	for _, param := range params {
+		b.WriteByte(';')
+		if err := writeKey(b, param.Key); err != nil {
+			return err
+		}
+		if param.Value == true {
+			continue
+		}
+		b.WriteByte('=')
+		if err := writeBareItem(b, param.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeKey(b *strings.Builder, key string) error {
+	if key == "" || (!isLCAlpha(key[0]) && key[0] != '*') {
+		return fmt.Errorf("sfv: invalid key %q", key)
+	}
+	for i := 1; i < len(key); i++ {
+		if !isKeyChar(key[i]) {
+			return fmt.Errorf("sfv: invalid key %q", key)
+		}
+	}
+	b.WriteString(key)
+	return nil
+}
+
+func writeBareItem(b *strings.Builder, value any) error {
+	switch v := value.(type) {
+	case int64:
+		if v > maxInteger || v < -maxInteger {
+			return fmt.Errorf("sfv: integer %d out of range", v)
+		}
+		b.WriteString(strconv.FormatInt(v, 10))
+	case int:
+		return writeBareItem(b, int64(v))
+	case float64:
+		// Round half to even to three decimals, RFC 8941 4.1.5.
+		rounded := math.RoundToEven(v*1000) / 1000
+		if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(rounded) >= 1e12 {
+			return fmt.Errorf("sfv: decimal %v out of range", v)
+		}
+		s := strconv.FormatFloat(rounded, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		b.WriteString(s)
+	case string:
+		b.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c < 0x20 || c > 0x7e {
+				return fmt.Errorf("sfv: invalid character %q in string", c)
+			}
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	case Token:
+		if v == "" || (v[0] != '*' && !isAlpha(v[0])) {
+			return fmt.Errorf("sfv: invalid token %q", v)
+		}
+		for i := 1; i < len(v); i++ {
+			if !isTokenChar(v[i]) {
+				return fmt.Errorf("sfv: invalid token %q", v)
+			}
+		}
+		b.WriteString(string(v))
+	case []byte:
+		b.WriteByte(':')
+		b.WriteString(base64.StdEncoding.EncodeToString(v))
+		b.WriteByte(':')
+	case bool:
+		if v {
+			b.WriteString("?1")
+		} else {
+			b.WriteString("?0")
+		}
+	default:
+		return fmt.Errorf("sfv: unsupported bare item type %T", value)
+	}
+	return nil
+}
+
+func isDigit(c byte) bool   { return '0' <= c && c <= '9' }
+func isLCAlpha(c byte) bool { return 'a' <= c && c <= 'z' }
+func isAlpha(c byte) bool   { return isLCAlpha(c) || ('A' <= c && c <= 'Z') }
+
+func isKeyChar(c byte) bool {
+	return isLCAlpha(c) || isDigit(c) || c == '_' || c == '-' || c == '.' || c == '*'
+}
+
+// isTokenChar allows tchar plus ':' and '/', RFC 8941 3.3.4.
+func isTokenChar(c byte) bool {
+	return isAlpha(c) || isDigit(c) || strings.IndexByte("!#$%&'*+-.^_`|~:/", c) != -1
+}
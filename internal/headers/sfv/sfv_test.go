@@ -0,0 +1,156 @@
+package sfv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseItem(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Item
+		// canonical is the serialization, if it differs from input.
+		canonical string
+	}{
+		{input: "42", want: Item{Value: int64(42)}},
+		{input: "-999999999999999", want: Item{Value: int64(-999999999999999)}},
+		{input: "4.5", want: Item{Value: 4.5}},
+		{input: "-0.125", want: Item{Value: -0.125}},
+		{input: "1.0", want: Item{Value: 1.0}},
+		{input: `"hello \"world\" \\"`, want: Item{Value: `hello "world" \`}},
+		{input: `""`, want: Item{Value: ""}},
+		{input: "text/html", want: Item{Value: Token("text/html")}},
+		{input: "*foo:bar", want: Item{Value: Token("*foo:bar")}},
+		{input: ":aGVsbG8=:", want: Item{Value: []byte("hello")}},
+		{input: "?1", want: Item{Value: true}},
+		{input: "?0", want: Item{Value: false}},
+		{
+			input: `abc;a=1;b="x";c;d=?0`,
+			want: Item{Value: Token("abc"), Params: Params{
+				{"a", int64(1)}, {"b", "x"}, {"c", true}, {"d", false},
+			}},
+		},
+		{
+			input:     "1; a=1;a=2",
+			want:      Item{Value: int64(1), Params: Params{{"a", int64(2)}}},
+			canonical: "1;a=2",
+		},
+		{input: "  7  ", want: Item{Value: int64(7)}, canonical: "7"},
+	}
+	for _, tt := range tests {
+		item, err := ParseItem(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, item, tt.input)
+
+		out, err := SerializeItem(item)
+		require.NoError(t, err, tt.input)
+		want := tt.canonical
+		if want == "" {
+			want = tt.input
+		}
+		assert.Equal(t, want, out, tt.input)
+	}
+
+	// Test: Invalid items
+	for _, input := range []string{
+		"", "1234567890123456", "1.2345", "1.", "1234567890123.0", "-", "-a",
+		`"unterminated`, `"bad \n escape"`, "\"tab\there\"", ":not base64!:", ":abc",
+		"?2", "1;A=1", "1;=2", "1 2", "Ω", "a,b",
+	} {
+		_, err := ParseItem(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	input := `sugar, tea;q=0.5, (rum "gin");x=?0, ()`
+	list, err := ParseList(input)
+	require.NoError(t, err)
+	assert.Equal(t, List{
+		Item{Value: Token("sugar")},
+		Item{Value: Token("tea"), Params: Params{{"q", 0.5}}},
+		InnerList{
+			Items:  []Item{{Value: Token("rum")}, {Value: "gin"}},
+			Params: Params{{"x", false}},
+		},
+		InnerList{Items: []Item{}},
+	}, list)
+	out, err := SerializeList(list)
+	require.NoError(t, err)
+	assert.Equal(t, input, out)
+
+	// Test: Empty field is an empty list
+	list, err = ParseList("")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	// Test: Invalid lists
+	for _, input := range []string{"a,", "a,,b", "a b", "(a", "(a,b)", ",a"} {
+		_, err := ParseList(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseDictionary(t *testing.T) {
+	input := `a=1, b, c;x=2, d=(1 2);y, e=?0`
+	dict, err := ParseDictionary(input)
+	require.NoError(t, err)
+	assert.Equal(t, Dictionary{
+		{"a", Item{Value: int64(1)}},
+		{"b", Item{Value: true}},
+		{"c", Item{Value: true, Params: Params{{"x", int64(2)}}}},
+		{"d", InnerList{Items: []Item{{Value: int64(1)}, {Value: int64(2)}}, Params: Params{{"y", true}}}},
+		{"e", Item{Value: false}},
+	}, dict)
+	out, err := SerializeDictionary(dict)
+	require.NoError(t, err)
+	assert.Equal(t, input, out)
+
+	m, ok := dict.Get("d")
+	require.True(t, ok)
+	assert.IsType(t, InnerList{}, m)
+	_, ok = dict.Get("z")
+	assert.False(t, ok)
+
+	// Test: Duplicate keys keep their first position and last value
+	dict, err = ParseDictionary("a=1, b=2, a=3")
+	require.NoError(t, err)
+	out, err = SerializeDictionary(dict)
+	require.NoError(t, err)
+	assert.Equal(t, "a=3, b=2", out)
+
+	// Test: Invalid dictionaries
+	for _, input := range []string{"A=1", "a=", "a=1,", "a=1 b=2", "1=a"} {
+		_, err := ParseDictionary(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestSerialize(t *testing.T) {
+	// Test: Decimals are rounded to three digits, half to even
+	out, err := SerializeItem(Item{Value: 1.0005})
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", out)
+	out, err = SerializeItem(Item{Value: 2.0})
+	require.NoError(t, err)
+	assert.Equal(t, "2.0", out)
+	out, err = SerializeItem(Item{Value: 7})
+	require.NoError(t, err)
+	assert.Equal(t, "7", out)
+
+	// Test: Values that cannot be represented
+	for _, item := range []Item{
+		{Value: int64(1_000_000_000_000_000)},
+		{Value: 1e12},
+		{Value: "café"},
+		{Value: Token("1abc")},
+		{Value: Token("a b")},
+		{Value: struct{}{}},
+		{Value: int64(1), Params: Params{{"Bad", true}}},
+	} {
+		_, err := SerializeItem(item)
+		assert.Error(t, err, item)
+	}
+}
@@ -0,0 +1,104 @@
+package headers
+
+import (
+	"errors"
+	"fmt"
+	"go-http/internal/httpdate"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissing is returned by the typed getters when the field is absent.
+var ErrMissing = errors.New("header field not present")
+
+// single returns the only value of key, failing if the field is absent or
+// repeated.
+func (h *Headers) single(key string) (string, error) {
+	values := h.Values(key)
+	switch len(values) {
+	case 0:
+		return "", fmt.Errorf("%s: %w", key, ErrMissing)
+	case 1:
+		return values[0], nil
+	}
+	return "", fmt.Errorf("%s sent %d times", key, len(values))
+}
+
+// ContentLength parses Content-Length. Only a single field holding a plain
+// decimal number is accepted; a repeated field or a list like "5, 5" is an
+// error rather than something to reconcile.
+func (h *Headers) ContentLength() (int64, error) {
+	value, err := h.single("Content-Length")
+	if err != nil {
+		return 0, err
+	}
+	if value == "" || strings.Trim(value, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid Content-Length %q", value)
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Length %q", value)
+	}
+	return n, nil
+}
+
+func (h *Headers) SetContentLength(n int64) {
+	h.Set("Content-Length", strconv.FormatInt(n, 10))
+}
+
+// ContentType parses Content-Type into its lowercased media type and
+// parameters.
+func (h *Headers) ContentType() (mediaType string, params map[string]string, err error) {
+	value, err := h.single("Content-Type")
+	if err != nil {
+		return "", nil, err
+	}
+	return ParseMediaType(value)
+}
+
+func (h *Headers) SetContentType(mediaType string, params map[string]string) {
+	h.Set("Content-Type", FormatMediaType(mediaType, params))
+}
+
+// Host returns the Host field, which may be empty but not repeated or a list.
+func (h *Headers) Host() (string, error) {
+	value, err := h.single("Host")
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(value, ",") {
+		return "", fmt.Errorf("invalid Host %q", value)
+	}
+	return value, nil
+}
+
+func (h *Headers) SetHost(host string) {
+	h.Set("Host", host)
+}
+
+// Date parses the Date field in any HTTP-date format.
+func (h *Headers) Date() (time.Time, error) {
+	return h.date("Date")
+}
+
+func (h *Headers) SetDate(t time.Time) {
+	h.Set("Date", httpdate.Format(t))
+}
+
+// LastModified parses the Last-Modified field in any HTTP-date format.
+func (h *Headers) LastModified() (time.Time, error) {
+	return h.date("Last-Modified")
+}
+
+func (h *Headers) SetLastModified(t time.Time) {
+	h.Set("Last-Modified", httpdate.Format(t))
+}
+
+func (h *Headers) date(key string) (time.Time, error) {
+	value, err := h.single(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return httpdate.Parse(value)
+}
@@ -0,0 +1,136 @@
+package headers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parseAll runs Parse over a whole header section.
+func parseAll(h *Headers, data string) error {
+	b := []byte(data)
+	for {
+		n, done, err := h.Parse(b)
+		if err != nil {
+			return err
+		}
+		if done || n == 0 {
+			return nil
+		}
+		b = b[n:]
+	}
+}
+
+func TestFieldValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		unfold  bool
+		want    []Field
+		wantErr bool
+	}{
+		{
+			name: "plain value",
+			data: "Accept: text/html\r\n\r\n",
+			want: []Field{{"Accept", "text/html"}},
+		},
+		{
+			name: "empty value",
+			data: "X-Empty:\r\n\r\n",
+			want: []Field{{"X-Empty", ""}},
+		},
+		{
+			name: "OWS is only SP and HTAB",
+			data: "X-A: \t value \t \r\n\r\n",
+			want: []Field{{"X-A", "value"}},
+		},
+		{
+			name: "inner whitespace kept",
+			data: "User-Agent: curl/8.0  (x86_64)\r\n\r\n",
+			want: []Field{{"User-Agent", "curl/8.0  (x86_64)"}},
+		},
+		{
+			name: "obs-text allowed",
+			data: "X-Name: J\xfcrgen\r\n\r\n",
+			want: []Field{{"X-Name", "J\xfcrgen"}},
+		},
+		{
+			name: "visible punctuation allowed",
+			data: "X-Chars: !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~\r\n\r\n",
+			want: []Field{{"X-Chars", "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"}},
+		},
+		{name: "NUL", data: "X-A: a\x00b\r\n\r\n", wantErr: true},
+		{name: "DEL", data: "X-A: a\x7fb\r\n\r\n", wantErr: true},
+		{name: "escape", data: "X-A: \x1b[31m\r\n\r\n", wantErr: true},
+		{name: "vertical tab", data: "X-A: a\vb\r\n\r\n", wantErr: true},
+		{name: "form feed around value", data: "X-A: \fvalue\r\n\r\n", wantErr: true},
+		{name: "bare CR in value", data: "X-A: a\rb\r\n\r\n", wantErr: true},
+		{name: "bare LF in value", data: "X-A: a\nX-B: b\r\n\r\n", wantErr: true},
+		{name: "LF line endings", data: "X-A: a\nX-B: b\n\n", wantErr: true},
+		{name: "bare CR ending line", data: "X-A: a\r\rX-B: b\r\n\r\n", wantErr: true},
+		{name: "space before colon", data: "X-A : a\r\n\r\n", wantErr: true},
+		{name: "tab before colon", data: "X-A\t: a\r\n\r\n", wantErr: true},
+		{name: "whitespace before first field", data: " X-A: a\r\n\r\n", wantErr: true},
+		{name: "whitespace before first field unfolding", data: " X-A: a\r\n\r\n", unfold: true, wantErr: true},
+		{name: "obs-fold rejected", data: "X-A: a\r\n b\r\n\r\n", wantErr: true},
+		{name: "obs-fold with colon rejected", data: "X-A: a\r\n X-B: b\r\n\r\n", wantErr: true},
+		{
+			name:   "obs-fold unfolded",
+			data:   "X-A: a\r\n  b\r\n\tc \r\nX-B: d\r\n\r\n",
+			unfold: true,
+			want:   []Field{{"X-A", "a b c"}, {"X-B", "d"}},
+		},
+		{
+			name:   "obs-fold of whitespace only",
+			data:   "X-A: a\r\n \t\r\n\r\n",
+			unfold: true,
+			want:   []Field{{"X-A", "a"}},
+		},
+		{
+			name:   "obs-fold onto empty value",
+			data:   "X-A:\r\n b\r\n\r\n",
+			unfold: true,
+			want:   []Field{{"X-A", "b"}},
+		},
+		{name: "obs-fold with control character", data: "X-A: a\r\n b\x00\r\n\r\n", unfold: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHeaders()
+			h.UnfoldObsFold = tt.unfold
+			err := parseAll(h, tt.data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, h.fields)
+		})
+	}
+}
+
+func TestValidateField(t *testing.T) {
+	tests := []struct {
+		name, value string
+		wantErr     bool
+	}{
+		{"Content-Type", "text/plain", false},
+		{"X-Tab", "a\tb", false},
+		{"X-Empty", "", false},
+		{"X-Split", "a\r\nSet-Cookie: x=1", true},
+		{"X-LF", "a\nb", true},
+		{"X-NUL", "a\x00", true},
+		{"Bad Name", "a", true},
+		{"", "a", true},
+		{"X-Colon:", "a", true},
+	}
+	for _, tt := range tests {
+		err := ValidateField(tt.name, tt.value)
+		if tt.wantErr {
+			assert.Error(t, err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+	}
+}
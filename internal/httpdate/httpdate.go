@@ -0,0 +1,48 @@
+// Package httpdate parses and formats HTTP-date values, RFC 9110 5.6.7.
+package httpdate
+
+import (
+	"fmt"
+	"time"
+)
+
+// IMF-fixdate is the preferred format and the only one Format produces. The
+// two obsolete formats are still accepted when parsing.
+const (
+	IMFFixdate = "Mon, 02 Jan 2006 15:04:05 GMT"
+	RFC850     = "Monday, 02-Jan-06 15:04:05 GMT"
+	ANSIC      = "Mon Jan _2 15:04:05 2006"
+)
+
+// Format returns t as an IMF-fixdate in GMT.
+func Format(t time.Time) string {
+	return t.UTC().Format(IMFFixdate)
+}
+
+// Parse parses a date in any of the three formats. The result is in UTC.
+func Parse(s string) (time.Time, error) {
+	return parse(s, time.Now())
+}
+
+func parse(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(IMFFixdate, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse(RFC850, s); err == nil {
+		return fixCentury(t, now).UTC(), nil
+	}
+	if t, err := time.Parse(ANSIC, s); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid HTTP-date %q", s)
+}
+
+// fixCentury applies the RFC 9110 rule for two-digit years: a year that
+// appears to be more than 50 years in the future is in the past century.
+func fixCentury(t, now time.Time) time.Time {
+	year := now.Year()/100*100 + t.Year()%100
+	if year > now.Year()+50 {
+		year -= 100
+	}
+	return t.AddDate(year-t.Year(), 0, 0)
+}
@@ -0,0 +1,61 @@
+package httpdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	want := time.Date(1994, time.November, 6, 8, 49, 37, 0, time.UTC)
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// Test: All three formats
+	for _, s := range []string{
+		"Sun, 06 Nov 1994 08:49:37 GMT",
+		"Sunday, 06-Nov-94 08:49:37 GMT",
+		"Sun Nov  6 08:49:37 1994",
+	} {
+		got, err := parse(s, now)
+		require.NoError(t, err, s)
+		assert.True(t, want.Equal(got), "%s: %v", s, got)
+		assert.Equal(t, time.UTC, got.Location())
+	}
+
+	// Test: Two-digit years are never more than 50 years ahead
+	got, err := parse("Thursday, 01-Jan-76 00:00:00 GMT", now)
+	require.NoError(t, err)
+	assert.Equal(t, 2076, got.Year())
+	got, err = parse("Friday, 01-Jan-77 00:00:00 GMT", now)
+	require.NoError(t, err)
+	assert.Equal(t, 1977, got.Year())
+	got, err = parse("Saturday, 01-Jan-30 00:00:00 GMT", now)
+	require.NoError(t, err)
+	assert.Equal(t, 2030, got.Year())
+
+	// Test: Invalid dates
+	for _, s := range []string{
+		"",
+		"Sun, 06 Nov 1994 08:49:37 UTC",
+		"Sun, 6 Nov 1994 08:49:37 GMT",
+		"06 Nov 1994 08:49:37 GMT",
+		"Sun, 31 Feb 1994 08:49:37 GMT",
+		"1994-11-06T08:49:37Z",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	assert.Equal(t, "Sun, 06 Nov 1994 08:49:37 GMT", Format(time.Date(1994, time.November, 6, 9, 49, 37, 0, loc)))
+
+	// Test: Round trip
+	now := time.Now().Truncate(time.Second)
+	got, err := Parse(Format(now))
+	require.NoError(t, err)
+	assert.True(t, now.Equal(got))
+}
@@ -0,0 +1,138 @@
+package multipart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go-http/internal/headers"
+	"io"
+	"os"
+)
+
+// ErrFormTooLarge is returned by ReadForm when the non-file fields do not
+// fit in the memory allowance.
+var ErrFormTooLarge = errors.New("multipart form too large")
+
+// Form is a fully parsed multipart/form-data body.
+type Form struct {
+	Value map[string][]string
+	File  map[string][]*FileHeader
+}
+
+// FileHeader describes a file part of a Form. Its content is either held in
+// memory or spooled to a temporary file, depending on its size.
+type FileHeader struct {
+	Filename string
+	Headers  *headers.Headers
+	Size     int64
+
+	content []byte
+	tmpfile string
+}
+
+// Open returns the content of the file.
+func (fh *FileHeader) Open() (io.ReadCloser, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return io.NopCloser(bytes.NewReader(fh.content)), nil
+}
+
+// RemoveAll deletes the temporary files backing the form.
+func (f *Form) RemoveAll() error {
+	var err error
+	for _, fhs := range f.File {
+		for _, fh := range fhs {
+			if fh.tmpfile == "" {
+				continue
+			}
+			if removeErr := os.Remove(fh.tmpfile); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) && err == nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
+
+// ReadForm reads every part of the body. Non-file fields are kept in memory
+// and together may not exceed maxMemory bytes. File parts are kept in memory
+// while they fit in what is left of maxMemory and are spooled to temporary
+// files otherwise. If maxParts is positive, bodies with more parts fail. On
+// error any temporary files created are removed.
+func (r *Reader) ReadForm(maxMemory int64, maxParts int) (*Form, error) {
+	form := &Form{
+		Value: make(map[string][]string),
+		File:  make(map[string][]*FileHeader),
+	}
+	if err := r.readForm(form, maxMemory, maxParts); err != nil {
+		form.RemoveAll()
+		return nil, err
+	}
+	return form, nil
+}
+
+func (r *Reader) readForm(form *Form, maxMemory int64, maxParts int) error {
+	remaining := maxMemory
+	for parts := 1; ; parts++ {
+		p, err := r.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if maxParts > 0 && parts > maxParts {
+			return fmt.Errorf("%w: more than %d parts", ErrFormTooLarge, maxParts)
+		}
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+
+		// Read one byte past what fits in memory to tell whether it does.
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, p, remaining+1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		filename := p.FileName()
+		if filename == "" {
+			if n > remaining {
+				return fmt.Errorf("%w: fields over %d bytes", ErrFormTooLarge, maxMemory)
+			}
+			remaining -= n
+			form.Value[name] = append(form.Value[name], buf.String())
+			continue
+		}
+
+		fh := &FileHeader{Filename: filename, Headers: p.Headers}
+		// Record the file before spooling so a failure still cleans it up.
+		form.File[name] = append(form.File[name], fh)
+		if n > remaining {
+			if err := fh.spool(&buf, p); err != nil {
+				return err
+			}
+			continue
+		}
+		remaining -= n
+		fh.content = buf.Bytes()
+		fh.Size = n
+	}
+}
+
+// spool writes the part, starting with what was already buffered, to a new
+// temporary file.
+func (fh *FileHeader) spool(buffered *bytes.Buffer, p *Part) error {
+	f, err := os.CreateTemp("", "multipart-")
+	if err != nil {
+		return err
+	}
+	fh.tmpfile = f.Name()
+	size, err := io.Copy(f, io.MultiReader(buffered, p))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	fh.Size = size
+	return err
+}
@@ -0,0 +1,229 @@
+package multipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go-http/internal/headers"
+	"io"
+	"strings"
+)
+
+// maxPartHeaderBytes caps the header section of a single part.
+const maxPartHeaderBytes = 16 << 10
+
+// bufferSize must leave room for a whole delimiter next to part content.
+const bufferSize = 4096
+
+// ErrMalformed is returned for bodies that do not follow RFC 2046 framing.
+var ErrMalformed = errors.New("malformed multipart body")
+
+// Reader iterates over the parts of a multipart body as they arrive, without
+// holding more than one buffer of it in memory.
+type Reader struct {
+	br *bufio.Reader
+	// dashBoundary is "--boundary", delim is the same preceded by CRLF. Every
+	// delimiter but the first has the CRLF, which belongs to it rather than
+	// to the content before it.
+	dashBoundary []byte
+	delim        []byte
+
+	part    *Part
+	started bool
+	done    bool
+}
+
+// NewReader reads parts from r, which holds a body using the given boundary.
+func NewReader(r io.Reader, boundary string) (*Reader, error) {
+	if boundary == "" || len(boundary) > 70 {
+		return nil, fmt.Errorf("%w: invalid boundary %q", ErrMalformed, boundary)
+	}
+	return &Reader{
+		br:           bufio.NewReaderSize(r, bufferSize),
+		dashBoundary: []byte("--" + boundary),
+		delim:        []byte("\r\n--" + boundary),
+	}, nil
+}
+
+// Part is a single part of a multipart body. Its content is read through the
+// part itself and ends at the next delimiter.
+type Part struct {
+	Headers *headers.Headers
+
+	mr          *Reader
+	done        bool
+	disposition string
+	params      map[string]string
+}
+
+// NextPart returns the next part, skipping whatever is left of the previous
+// one. It returns io.EOF after the last part.
+func (r *Reader) NextPart() (*Part, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+	if r.part != nil {
+		if _, err := io.Copy(io.Discard, r.part); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if !r.started {
+		r.started = true
+		err = r.skipPreamble()
+	} else {
+		err = r.nextDelimiter()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.done {
+		return nil, io.EOF
+	}
+
+	h, err := r.readHeaders()
+	if err != nil {
+		return nil, err
+	}
+	r.part = &Part{Headers: h, mr: r}
+	if cd, ok := h.Get("Content-Disposition"); ok {
+		r.part.disposition, r.part.params, _ = headers.ParseContentDisposition(cd)
+	}
+	return r.part, nil
+}
+
+// skipPreamble discards lines up to and including the first delimiter.
+func (r *Reader) skipPreamble() error {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(line, r.dashBoundary) {
+			continue
+		}
+		switch rest := line[len(r.dashBoundary):]; {
+		case bytes.HasPrefix(rest, []byte("--")):
+			r.done = true
+			return nil
+		case len(bytes.TrimRight(rest, " \t")) == 0:
+			return nil
+		}
+	}
+}
+
+// nextDelimiter consumes the delimiter that ended the previous part, noting
+// whether it was the closing one.
+func (r *Reader) nextDelimiter() error {
+	if _, err := r.br.Discard(len(r.delim)); err != nil {
+		return err
+	}
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(line, []byte("--")) {
+		// The epilogue after the closing delimiter is ignored.
+		r.done = true
+		return nil
+	}
+	if len(bytes.TrimRight(line, " \t")) != 0 {
+		return fmt.Errorf("%w: unexpected %q after delimiter", ErrMalformed, line)
+	}
+	return nil
+}
+
+// readLine returns the next line without its CRLF. Overlong lines are
+// returned in pieces, which is harmless since only delimiter lines matter.
+func (r *Reader) readLine() ([]byte, error) {
+	line, err := r.br.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return line, nil
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: %w", ErrMalformed, io.ErrUnexpectedEOF)
+		}
+		return nil, err
+	}
+	return bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r")), nil
+}
+
+func (r *Reader) readHeaders() (*headers.Headers, error) {
+	h := headers.NewHeaders()
+	size := 0
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, bufio.ErrBufferFull) {
+				return nil, fmt.Errorf("%w: bad part header", ErrMalformed)
+			}
+			return nil, err
+		}
+		size += len(line)
+		if size > maxPartHeaderBytes {
+			return nil, fmt.Errorf("%w: part header over %d bytes", ErrMalformed, maxPartHeaderBytes)
+		}
+		_, done, err := h.Parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if done {
+			return h, nil
+		}
+	}
+}
+
+// Read reads the content of the part up to the next delimiter.
+func (p *Part) Read(b []byte) (int, error) {
+	if p.done {
+		return 0, io.EOF
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	br := p.mr.br
+	peek, err := br.Peek(bufferSize)
+	if i := bytes.Index(peek, p.mr.delim); i != -1 {
+		if i == 0 {
+			p.done = true
+			return 0, io.EOF
+		}
+		n := copy(b, peek[:i])
+		br.Discard(n)
+		return n, nil
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, fmt.Errorf("%w: %w", ErrMalformed, io.ErrUnexpectedEOF)
+		}
+		return 0, err
+	}
+	// The tail of the buffer could be the start of a delimiter, so hold it
+	// back until more data shows whether it is.
+	n := copy(b, peek[:len(peek)-len(p.mr.delim)+1])
+	br.Discard(n)
+	return n, nil
+}
+
+// FormName returns the name parameter of a form-data Content-Disposition, or
+// "" for other parts.
+func (p *Part) FormName() string {
+	if p.disposition != "form-data" {
+		return ""
+	}
+	return p.params["name"]
+}
+
+// FileName returns the filename parameter of the Content-Disposition, or ""
+// if the part is not a file. Any directory the client included is dropped,
+// RFC 7578 4.2.
+func (p *Part) FileName() string {
+	name := p.params["filename"]
+	if i := strings.LastIndexAny(name, `/\`); i != -1 {
+		name = name[i+1:]
+	}
+	return name
+}
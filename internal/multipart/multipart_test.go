@@ -0,0 +1,140 @@
+package multipart
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBody = "preamble to ignore\r\n" +
+	"--xyz\r\n" +
+	"Content-Disposition: form-data; name=\"title\"\r\n" +
+	"\r\n" +
+	"hello\r\nworld\r\n" +
+	"--xyz  \r\n" +
+	"Content-Disposition: form-data; name=\"upload\"; filename=\"C:\\\\docs\\\\a.txt\"\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"file --xy content\r\n" +
+	"--xyz\r\n" +
+	"Content-Disposition: form-data; name=\"empty\"\r\n" +
+	"\r\n" +
+	"\r\n" +
+	"--xyz--\r\n" +
+	"epilogue"
+
+func TestReader(t *testing.T) {
+	// Test: Parts read one byte at a time
+	mr, err := NewReader(iotest.OneByteReader(strings.NewReader(testBody)), "xyz")
+	require.NoError(t, err)
+
+	p, err := mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "title", p.FormName())
+	assert.Equal(t, "", p.FileName())
+	data, err := io.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\r\nworld", string(data))
+
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "upload", p.FormName())
+	assert.Equal(t, "a.txt", p.FileName())
+	contentType, _ := p.Headers.Get("Content-Type")
+	assert.Equal(t, "text/plain", contentType)
+	data, err = io.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, "file --xy content", string(data))
+
+	// Test: Unread parts are skipped
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "empty", p.FormName())
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+	_, err = mr.NextPart()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Content larger than the buffer
+	big := strings.Repeat("0123456789", 1000)
+	mr, err = NewReader(strings.NewReader(
+		"--b\r\nContent-Disposition: form-data; name=\"f\"\r\n\r\n"+big+"\r\n--b--\r\n"), "b")
+	require.NoError(t, err)
+	p, err = mr.NextPart()
+	require.NoError(t, err)
+	data, err = io.ReadAll(p)
+	require.NoError(t, err)
+	assert.Equal(t, big, string(data))
+
+	// Test: Malformed bodies
+	for _, body := range []string{
+		"--b\r\nContent-Disposition: form-data; name=\"f\"\r\n\r\nno closing delimiter",
+		"--b\r\nBad Header\r\n\r\nx\r\n--b--\r\n",
+		"--b\r\n\r\nx\r\n--bogus\r\n\r\ny\r\n--b--\r\n",
+		"no delimiter at all",
+	} {
+		mr, err := NewReader(strings.NewReader(body), "b")
+		require.NoError(t, err)
+		_, err = mr.ReadForm(1<<20, 0)
+		assert.ErrorIs(t, err, ErrMalformed, body)
+	}
+
+	// Test: Invalid boundary
+	_, err = NewReader(strings.NewReader(""), "")
+	assert.ErrorIs(t, err, ErrMalformed)
+}
+
+func TestReadForm(t *testing.T) {
+	// Test: Fields and small files stay in memory
+	mr, err := NewReader(strings.NewReader(testBody), "xyz")
+	require.NoError(t, err)
+	form, err := mr.ReadForm(1<<20, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello\r\nworld"}, form.Value["title"])
+	assert.Equal(t, []string{""}, form.Value["empty"])
+	require.Len(t, form.File["upload"], 1)
+	fh := form.File["upload"][0]
+	assert.Equal(t, "a.txt", fh.Filename)
+	assert.Equal(t, int64(17), fh.Size)
+	assert.Empty(t, fh.tmpfile)
+	assertContent(t, fh, "file --xy content")
+
+	// Test: Files over the memory allowance are spooled to disk
+	mr, err = NewReader(strings.NewReader(testBody), "xyz")
+	require.NoError(t, err)
+	form, err = mr.ReadForm(15, 0)
+	require.NoError(t, err)
+	fh = form.File["upload"][0]
+	require.NotEmpty(t, fh.tmpfile)
+	assertContent(t, fh, "file --xy content")
+	require.NoError(t, form.RemoveAll())
+	_, err = os.Stat(fh.tmpfile)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// Test: Fields over the memory allowance
+	mr, err = NewReader(strings.NewReader(testBody), "xyz")
+	require.NoError(t, err)
+	_, err = mr.ReadForm(5, 0)
+	assert.ErrorIs(t, err, ErrFormTooLarge)
+
+	// Test: Too many parts
+	mr, err = NewReader(strings.NewReader(testBody), "xyz")
+	require.NoError(t, err)
+	_, err = mr.ReadForm(1<<20, 2)
+	assert.ErrorIs(t, err, ErrFormTooLarge)
+}
+
+func assertContent(t *testing.T, fh *FileHeader, want string) {
+	t.Helper()
+	f, err := fh.Open()
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
@@ -0,0 +1,292 @@
+package request
+
+import (
+	"bytes"
+	"errors"
+	"go-http/internal/headers"
+	"io"
+	"os"
+	"strconv"
+)
+
+// ErrBodyClosed is returned when reading a body after it has been closed.
+var ErrBodyClosed = errors.New("read on closed body")
+
+// bodyReader is a request body read lazily from the connection.
+type bodyReader interface {
+	io.ReadCloser
+	// pending reports whether unread body bytes are left on the connection.
+	pending() bool
+	// discard drains the rest of the body, failing if it exceeds max bytes.
+	discard(max int64) error
+}
+
+// body reads a request body of known length.
+type body struct {
+	reader    *Reader
+	remaining int64
+	closed    bool
+	err       error
+}
+
+func (b *body) Read(p []byte) (int, error) {
+	if b.closed {
+		return 0, ErrBodyClosed
+	}
+	return b.read(p)
+}
+
+func (b *body) read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.remaining == 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.reader.read(p)
+	b.remaining -= int64(n)
+	if err != nil {
+		if errors.Is(err, io.EOF) && b.remaining > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		err = timeoutError(err)
+		b.err = err
+		if n > 0 {
+			return n, nil
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+// Close stops the handler from reading further. Unread bytes stay on the
+// connection until the server discards them.
+func (b *body) Close() error {
+	b.closed = true
+	return nil
+}
+
+func (b *body) pending() bool {
+	return b.remaining > 0
+}
+
+func (b *body) discard(max int64) error {
+	if b.remaining > max {
+		return errors.New("too many unread body bytes to discard")
+	}
+	return discardAll(b.read, max)
+}
+
+// maxChunkLineBytes caps a chunk-size line including its extensions.
+const maxChunkLineBytes = 4096
+
+type chunkedState int
+
+const (
+	chunkedStateSize chunkedState = iota
+	chunkedStateData
+	chunkedStateDataEnd
+	chunkedStateDone
+)
+
+// chunkedBody decodes a body sent with Transfer-Encoding: chunked:
+//
+//	<hex size>[;ext[=val]]\r\n
+//	<data>\r\n
+//	...
+//	0\r\n
+//	<trailer fields>\r\n
+type chunkedBody struct {
+	reader    *Reader
+	trailers  *headers.Headers
+	limits    Limits
+	state     chunkedState
+	remaining int64 // bytes left in the current chunk
+	total     int64 // decoded bytes so far
+	closed    bool
+	err       error
+}
+
+func (c *chunkedBody) Read(p []byte) (int, error) {
+	if c.closed {
+		return 0, ErrBodyClosed
+	}
+	return c.read(p)
+}
+
+func (c *chunkedBody) read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.step(p)
+	if err != nil {
+		if errors.Is(err, io.EOF) && c.state != chunkedStateDone {
+			err = io.ErrUnexpectedEOF
+		}
+		err = timeoutError(err)
+		c.err = err
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return n, err
+}
+
+// step advances the decoder until it has data for p or the body ends.
+func (c *chunkedBody) step(p []byte) (int, error) {
+	for {
+		switch c.state {
+		case chunkedStateSize:
+			line, err := c.reader.readLine(maxChunkLineBytes)
+			if err != nil {
+				return 0, err
+			}
+			size, err := parseChunkSize(line)
+			if err != nil {
+				return 0, err
+			}
+			if size == 0 {
+				if err := c.readTrailers(); err != nil {
+					return 0, err
+				}
+				c.state = chunkedStateDone
+				return 0, io.EOF
+			}
+			c.total += size
+			if c.limits.MaxBodyBytes > 0 && c.total > c.limits.MaxBodyBytes {
+				return 0, newError(413, ErrBodyTooLarge, "more than %d bytes", c.limits.MaxBodyBytes)
+			}
+			c.remaining = size
+			c.state = chunkedStateData
+
+		case chunkedStateData:
+			if len(p) == 0 {
+				return 0, nil
+			}
+			if int64(len(p)) > c.remaining {
+				p = p[:c.remaining]
+			}
+			n, err := c.reader.read(p)
+			c.remaining -= int64(n)
+			if c.remaining == 0 {
+				c.state = chunkedStateDataEnd
+			}
+			return n, err
+
+		case chunkedStateDataEnd:
+			line, err := c.reader.readLine(maxChunkLineBytes)
+			if err != nil {
+				return 0, err
+			}
+			if len(line) != 0 {
+				return 0, newError(400, ErrMalformedBody, "chunk data not followed by CRLF")
+			}
+			c.state = chunkedStateSize
+
+		case chunkedStateDone:
+			return 0, io.EOF
+		}
+	}
+}
+
+// readTrailers parses the trailer section that follows the last chunk.
+func (c *chunkedBody) readTrailers() error {
+	r := c.reader
+	size := 0
+	for {
+		n, done, err := c.trailers.Parse(r.buf[:r.readToIdx])
+		if err != nil {
+			return newError(400, ErrInvalidHeader, "trailer: %v", err)
+		}
+		r.consume(n)
+		size += n
+		if done {
+			return nil
+		}
+		if max := c.limits.MaxHeaderBytes; max > 0 && size+r.readToIdx > max {
+			return newError(431, ErrHeaderTooLarge, "trailers over %d bytes", max)
+		}
+		if n > 0 {
+			continue
+		}
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *chunkedBody) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *chunkedBody) pending() bool {
+	return c.state != chunkedStateDone
+}
+
+func (c *chunkedBody) discard(max int64) error {
+	return discardAll(c.read, max)
+}
+
+// parseChunkSize parses a chunk-size line, skipping any chunk extensions.
+func parseChunkSize(line []byte) (int64, error) {
+	sizeText, ext, hasExt := bytes.Cut(line, []byte(";"))
+	sizeText = bytes.TrimRight(sizeText, " \t")
+	if len(sizeText) == 0 || len(sizeText) > 16 {
+		return 0, newError(400, ErrMalformedBody, "invalid chunk size: %q", line)
+	}
+	size, err := strconv.ParseUint(string(sizeText), 16, 64)
+	if err != nil || size > 1<<62 {
+		return 0, newError(400, ErrMalformedBody, "invalid chunk size: %q", line)
+	}
+	if hasExt {
+		if err := validChunkExtensions(ext); err != nil {
+			return 0, err
+		}
+	}
+	return int64(size), nil
+}
+
+// validChunkExtensions checks the "name[=value]" list after a chunk size.
+// Extensions carry no meaning for this server so they are otherwise ignored.
+func validChunkExtensions(ext []byte) error {
+	for _, part := range bytes.Split(ext, []byte(";")) {
+		name, _, _ := bytes.Cut(part, []byte("="))
+		if len(bytes.Trim(name, " \t")) == 0 {
+			return newError(400, ErrMalformedBody, "invalid chunk extension: %q", ext)
+		}
+	}
+	return nil
+}
+
+// timeoutError turns a read deadline hit while reading the body into the
+// same 408 error ReadRequest returns for the head.
+func timeoutError(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return newError(408, ErrTimeout, "reading body")
+	}
+	return err
+}
+
+// discardAll reads from read until EOF, failing once more than max bytes
+// have been seen.
+func discardAll(read func([]byte) (int, error), max int64) error {
+	buf := make([]byte, 4096)
+	var total int64
+	for {
+		n, err := read(buf)
+		total += int64(n)
+		if total > max {
+			return errors.New("too many unread body bytes to discard")
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
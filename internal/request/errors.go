@@ -0,0 +1,54 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of request errors. They are wrapped in an *Error carrying the status
+// to answer with and can be matched with errors.Is.
+var (
+	ErrMalformedRequestLine      = errors.New("malformed request line")
+	ErrUnsupportedVersion        = errors.New("unsupported HTTP version")
+	ErrInvalidTarget             = errors.New("invalid request target")
+	ErrInvalidHeader             = errors.New("invalid header field")
+	ErrInvalidHost               = errors.New("missing or duplicate Host header")
+	ErrInvalidContentLength      = errors.New("invalid Content-Length")
+	ErrAmbiguousFraming          = errors.New("both Transfer-Encoding and Content-Length present")
+	ErrUnsupportedTransferCoding = errors.New("unsupported transfer coding")
+	ErrMalformedBody             = errors.New("malformed body")
+	ErrIncomplete                = errors.New("incomplete request")
+	ErrTimeout                   = errors.New("timed out reading request")
+	ErrRequestLineTooLong        = errors.New("request line too long")
+	ErrHeaderTooLarge            = errors.New("request header fields too large")
+	ErrBodyTooLarge              = errors.New("request body too large")
+	ErrMalformedForm             = errors.New("malformed form data")
+)
+
+// Error describes a request that could not be read. StatusCode is the HTTP
+// status the server should answer with and Kind one of the Err values above.
+// Detail holds specifics for logs and must not be sent to clients.
+type Error struct {
+	StatusCode int
+	Kind       error
+	Detail     string
+}
+
+func newError(statusCode int, kind error, format string, args ...any) *Error {
+	return &Error{
+		StatusCode: statusCode,
+		Kind:       kind,
+		Detail:     fmt.Sprintf(format, args...),
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Detail == "" {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
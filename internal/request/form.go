@@ -0,0 +1,146 @@
+package request
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// Values maps field names to their values in the order they were sent. Names
+// are case-sensitive.
+type Values map[string][]string
+
+// Get returns the first value of key, or "" if there is none.
+func (v Values) Get(key string) string {
+	if vs := v[key]; len(vs) > 0 {
+		return vs[0]
+	}
+	return ""
+}
+
+// Has reports whether key was sent, even with an empty value.
+func (v Values) Has(key string) bool {
+	_, ok := v[key]
+	return ok
+}
+
+// Query parses the query of the request-target. The result is cached, so it
+// is cheap to call repeatedly.
+func (r *Request) Query() (Values, error) {
+	if r.query != nil {
+		return r.query, nil
+	}
+	var rawQuery string
+	if r.URL != nil {
+		rawQuery = r.URL.RawQuery
+	}
+	query, err := parseValues(rawQuery, r.limits.MaxFormFields)
+	if err != nil {
+		return nil, err
+	}
+	r.query = query
+	return query, nil
+}
+
+// Form parses an application/x-www-form-urlencoded body, reading it into
+// memory up to Limits.MaxFormBytes. Requests with another content type have
+// an empty form; query parameters are available from Query instead. Like
+// BodyBytes, it leaves Body readable from the start.
+func (r *Request) Form() (Values, error) {
+	if r.form != nil {
+		return r.form, nil
+	}
+	mediaType, _, _ := r.Headers.ContentType()
+	if mediaType != "application/x-www-form-urlencoded" {
+		r.form = Values{}
+		return r.form, nil
+	}
+
+	data := r.bodyBytes
+	if data == nil {
+		var err error
+		data, err = r.readForm()
+		if err != nil {
+			return nil, err
+		}
+	}
+	form, err := parseValues(string(data), r.limits.MaxFormFields)
+	if err != nil {
+		return nil, err
+	}
+	r.form = form
+	return form, nil
+}
+
+// readForm reads the body for Form, failing with 413 past MaxFormBytes.
+func (r *Request) readForm() ([]byte, error) {
+	body := r.Body
+	max := r.limits.MaxFormBytes
+	if max > 0 {
+		body = io.NopCloser(io.LimitReader(r.Body, max+1))
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if max > 0 && int64(len(data)) > max {
+		return nil, newError(413, ErrBodyTooLarge, "form over %d bytes", max)
+	}
+	r.bodyBytes = data
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// parseValues decodes "a=1&b=2&a=3". Pairs without "=" get an empty value and
+// empty pairs are skipped. Parsing fails on an invalid escape or once more
+// than maxFields fields are seen, if maxFields is positive.
+func parseValues(s string, maxFields int) (Values, error) {
+	values := Values{}
+	fields := 0
+	for s != "" {
+		var pair string
+		pair, s, _ = strings.Cut(s, "&")
+		if pair == "" {
+			continue
+		}
+		fields++
+		if maxFields > 0 && fields > maxFields {
+			return nil, newError(400, ErrMalformedForm, "more than %d fields", maxFields)
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, ok := formUnescape(rawKey)
+		if !ok {
+			return nil, newError(400, ErrMalformedForm, "invalid escape in %q", rawKey)
+		}
+		value, ok := formUnescape(rawValue)
+		if !ok {
+			return nil, newError(400, ErrMalformedForm, "invalid escape in %q", rawValue)
+		}
+		values[key] = append(values[key], value)
+	}
+	return values, nil
+}
+
+// formUnescape decodes percent-escapes and turns "+" into a space, as the
+// urlencoded format requires.
+func formUnescape(s string) (string, bool) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, true
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '+':
+			b.WriteByte(' ')
+		case '%':
+			if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+				return "", false
+			}
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), true
+}
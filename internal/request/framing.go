@@ -0,0 +1,78 @@
+package request
+
+import (
+	"errors"
+	"go-http/internal/headers"
+	"strings"
+)
+
+// framing works out how the body is delimited, following RFC 9112 6.3. Any
+// ambiguity is rejected rather than resolved, since a proxy in front of the
+// server may have resolved it differently and that is how requests get
+// smuggled.
+func (r *Request) framing() (chunked bool, contentLength int64, err error) {
+	transferEncodings := r.Headers.Values("Transfer-Encoding")
+	_, hasCL := r.Headers.Get("Content-Length")
+	hasTE := len(transferEncodings) > 0
+
+	if hasTE && hasCL {
+		return false, 0, newError(400, ErrAmbiguousFraming, "")
+	}
+
+	if hasTE {
+		// HTTP/1.0 has no transfer codings, so a sender using one can't
+		// be trusted to have framed the message the way we would read it.
+		if r.RequestLine.HttpVersion == "1.0" {
+			return false, 0, newError(400, ErrInvalidHeader, "Transfer-Encoding in HTTP/1.0 request")
+		}
+		transferEncoding := strings.Join(transferEncodings, ", ")
+		codings, err := headers.ParseList(transferEncoding)
+		if err != nil || len(codings) == 0 {
+			return false, 0, newError(400, ErrInvalidHeader, "Transfer-Encoding %q", transferEncoding)
+		}
+		for i, coding := range codings {
+			if !strings.EqualFold(coding, "chunked") {
+				return false, 0, newError(501, ErrUnsupportedTransferCoding, "%q", coding)
+			}
+			if i != len(codings)-1 {
+				return false, 0, newError(400, ErrInvalidHeader, "chunked applied more than once: %q", transferEncoding)
+			}
+		}
+		return true, 0, nil
+	}
+
+	if hasCL {
+		// Even identical duplicates are rejected, along with a list like
+		// "5, 5" folded into one field.
+		contentLength, err := r.Headers.ContentLength()
+		if err != nil {
+			return false, 0, newError(400, ErrInvalidContentLength, "%v", err)
+		}
+		return false, contentLength, nil
+	}
+
+	return false, 0, nil
+}
+
+// resolveHost requires exactly one well-formed Host field, as HTTP/1.1
+// mandates; HTTP/1.0 requests may omit it but still not repeat it. The
+// authority of the request-target takes precedence over the field, RFC 9112
+// 3.2.2.
+func (r *Request) resolveHost() error {
+	host, err := r.Headers.Host()
+	switch {
+	case errors.Is(err, headers.ErrMissing):
+		if r.RequestLine.HttpVersion != "1.0" {
+			return newError(400, ErrInvalidHost, "")
+		}
+	case err != nil:
+		return newError(400, ErrInvalidHost, "%v", err)
+	case !validHost(host):
+		return newError(400, ErrInvalidHost, "%q", host)
+	}
+	r.Host = host
+	if r.URL.Host != "" {
+		r.Host = r.URL.Host
+	}
+	return nil
+}
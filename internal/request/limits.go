@@ -0,0 +1,36 @@
+package request
+
+// Limits bounds how much a client may send in one request. A zero value for
+// any field disables that limit.
+type Limits struct {
+	// MaxRequestLineBytes caps the request line, excluding its CRLF.
+	MaxRequestLineBytes int
+	// MaxHeaderBytes caps the header section, and separately the trailer
+	// section of a chunked body.
+	MaxHeaderBytes int
+	// MaxHeaderCount caps the number of header field lines.
+	MaxHeaderCount int
+	// MaxBodyBytes caps the decoded body.
+	MaxBodyBytes int64
+	// MaxFormBytes caps a urlencoded body read by Request.Form.
+	MaxFormBytes int64
+	// MaxFormFields caps the number of fields Request.Query, Request.Form
+	// or Request.MultipartForm will parse.
+	MaxFormFields int
+	// MaxMultipartMemory caps how much of a multipart form is kept in
+	// memory. Files that don't fit are spooled to temporary files.
+	MaxMultipartMemory int64
+}
+
+// DefaultLimits returns the limits used by NewReader.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxRequestLineBytes: 8 << 10,
+		MaxHeaderBytes:      64 << 10,
+		MaxHeaderCount:      100,
+		MaxBodyBytes:        10 << 20,
+		MaxFormBytes:        1 << 20,
+		MaxFormFields:       1000,
+		MaxMultipartMemory:  10 << 20,
+	}
+}
@@ -0,0 +1,61 @@
+package request
+
+import (
+	"errors"
+	"go-http/internal/multipart"
+	"math"
+)
+
+// MultipartReader returns a reader over the parts of a multipart/form-data
+// body, for handlers that want to stream uploads themselves. It fails for
+// other content types.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	mediaType, params, err := r.Headers.ContentType()
+	if err != nil || mediaType != "multipart/form-data" {
+		return nil, newError(400, ErrMalformedForm, "content type %q is not multipart/form-data", mediaType)
+	}
+	mr, err := multipart.NewReader(r.Body, params["boundary"])
+	if err != nil {
+		return nil, newError(400, ErrMalformedForm, "%v", err)
+	}
+	return mr, nil
+}
+
+// MultipartForm parses the whole multipart/form-data body. Fields and small
+// files are kept in memory up to Limits.MaxMultipartMemory, larger files are
+// spooled to temporary files that are removed by Cleanup. The result is
+// cached.
+func (r *Request) MultipartForm() (*multipart.Form, error) {
+	if r.multipart != nil {
+		return r.multipart, nil
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	maxMemory := r.limits.MaxMultipartMemory
+	if maxMemory <= 0 {
+		maxMemory = math.MaxInt64 - 1
+	}
+	form, err := mr.ReadForm(maxMemory, r.limits.MaxFormFields)
+	switch {
+	case errors.Is(err, multipart.ErrFormTooLarge):
+		return nil, newError(413, ErrBodyTooLarge, "%v", err)
+	case errors.Is(err, multipart.ErrMalformed):
+		return nil, newError(400, ErrMalformedForm, "%v", err)
+	case err != nil:
+		return nil, err
+	}
+	r.multipart = form
+	return form, nil
+}
+
+// Cleanup releases what the request holds beyond its handler, such as the
+// temporary files of a multipart form. The server calls it once the handler
+// has returned.
+func (r *Request) Cleanup() error {
+	if r.multipart == nil {
+		return nil
+	}
+	return r.multipart.RemoveAll()
+}
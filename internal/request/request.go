@@ -5,8 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"go-http/internal/headers"
+	"go-http/internal/multipart"
 	"io"
-	"strconv"
+	"os"
 	"strings"
 )
 
@@ -18,16 +19,34 @@ type requestState int
 const (
 	readerStateInitialized requestState = iota
 	readerStateParsingHeaders
-	readerStateParsingBody
 	readerStateDone
 )
 
 type Request struct {
-	RequestLine    RequestLine
-	Headers        headers.Headers
-	Body           []byte
-	bodyLengthRead int
-	state          requestState
+	RequestLine RequestLine
+	// URL is the parsed RequestLine.RequestTarget.
+	URL *URL
+	// Host is the host the request is for: the authority of an absolute- or
+	// authority-form target, otherwise the Host header. It may be empty for
+	// HTTP/1.0 requests.
+	Host    string
+	Headers *headers.Headers
+	// Body streams the request body from the connection as it is read. It
+	// is never nil; requests without a body return io.EOF immediately.
+	Body io.ReadCloser
+	// Trailers holds the trailer fields of a chunked body. It is filled in
+	// once the body has been read to the end.
+	Trailers    *headers.Headers
+	body        bodyReader
+	bodyBytes   []byte
+	state       requestState
+	headerBytes int
+	headerCount int
+	pathValues  map[string]string
+	limits      Limits
+	query       Values
+	form        Values
+	multipart   *multipart.Form
 }
 
 type RequestLine struct {
@@ -36,49 +55,281 @@ type RequestLine struct {
 	Method        string
 }
 
+// Reader reads consecutive requests from a single connection. Bytes read past
+// the end of one request are kept in the buffer for the next one, so
+// pipelined requests on a persistent connection are not lost.
+type Reader struct {
+	// Limits applies to every request read. NewReader sets DefaultLimits.
+	Limits Limits
+	// UnfoldObsFold accepts obsolete line folding in headers and trailers,
+	// see headers.Headers.UnfoldObsFold.
+	UnfoldObsFold bool
+
+	reader    io.Reader
+	buf       []byte
+	readToIdx int
+	// body is the body of the last request, which has to be consumed before
+	// the next request can be parsed.
+	body bodyReader
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{
+		Limits: DefaultLimits(),
+		reader: reader,
+		buf:    make([]byte, bufferSize),
+	}
+}
+
+// RequestFromReader parses a single request and reads its whole body into
+// memory. Servers handling many requests per connection should use a Reader,
+// which streams bodies instead.
 func RequestFromReader(reader io.Reader) (*Request, error) {
-	req := &Request{
-		Headers: headers.NewHeaders(),
-		Body:    make([]byte, 0),
-		state:   readerStateInitialized,
+	req, err := NewReader(reader).ReadRequest()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := req.BodyBytes(); err != nil {
+		return nil, err
 	}
+	return req, nil
+}
 
-	buf := make([]byte, bufferSize)
-	readToIdx := 0
+// Wait blocks until at least one byte of the next request is available. It
+// lets callers tell an idle connection apart from one with a request in
+// flight.
+func (r *Reader) Wait() error {
+	for r.readToIdx == 0 {
+		if err := r.fill(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	for req.state != readerStateDone {
-		if readToIdx >= len(buf) {
-			newBuf := make([]byte, len(buf)*2)
-			copy(newBuf, buf)
-			buf = newBuf
+// ReadRequest parses the request line and headers of the next request. The
+// body is left on the connection and read through Request.Body; it must be
+// consumed or discarded before the next call. ReadRequest returns io.EOF when
+// the connection is closed cleanly before any byte of a new request.
+func (r *Reader) ReadRequest() (*Request, error) {
+	if r.body != nil && r.body.pending() {
+		return nil, errors.New("previous request body was not consumed")
+	}
+
+	req := &Request{
+		Headers:  headers.NewHeaders(),
+		Trailers: headers.NewHeaders(),
+		state:    readerStateInitialized,
+		limits:   r.Limits,
+	}
+	req.Headers.UnfoldObsFold = r.UnfoldObsFold
+	req.Trailers.UnfoldObsFold = r.UnfoldObsFold
+	for {
+		if err := r.checkRequestLine(req); err != nil {
+			return nil, err
 		}
 
-		numBytesRead, err := reader.Read(buf[readToIdx:])
+		numBytesParsed, err := req.parse(r.buf[:r.readToIdx])
 		if err != nil {
+			return nil, err
+		}
+
+		r.consume(numBytesParsed)
+
+		if err := r.checkHeaders(req); err != nil {
+			return nil, err
+		}
+		if req.state == readerStateDone {
+			break
+		}
+
+		if err := r.fill(); err != nil {
 			if errors.Is(err, io.EOF) {
-				return nil, fmt.Errorf("Incomplete request. State: %d, read: %d bytes then got EOF", req.state, numBytesRead)
+				if req.state == readerStateInitialized && r.readToIdx == 0 {
+					return nil, io.EOF
+				}
+				return nil, newError(400, ErrIncomplete, "state: %d, read: %d bytes then got EOF", req.state, r.readToIdx)
+			}
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, newError(408, ErrTimeout, "")
 			}
 			return nil, err
 		}
-		readToIdx += numBytesRead
+	}
 
-		numBytesParsed, err := req.parse(buf[:readToIdx])
-		if err != nil {
+	if err := req.resolveHost(); err != nil {
+		return nil, err
+	}
+	chunked, contentLength, err := req.framing()
+	if err != nil {
+		return nil, err
+	}
+	if chunked {
+		req.body = &chunkedBody{reader: r, trailers: req.Trailers, limits: r.Limits}
+	} else {
+		if r.Limits.MaxBodyBytes > 0 && contentLength > r.Limits.MaxBodyBytes {
+			return nil, newError(413, ErrBodyTooLarge, "Content-Length %d", contentLength)
+		}
+		req.body = &body{reader: r, remaining: contentLength}
+	}
+	req.Body = req.body
+	r.body = req.body
+	return req, nil
+}
+
+// checkRequestLine fails once the request line being received is longer
+// than the limit, whether or not its CRLF has arrived yet.
+func (r *Reader) checkRequestLine(req *Request) error {
+	max := r.Limits.MaxRequestLineBytes
+	if max <= 0 || req.state != readerStateInitialized {
+		return nil
+	}
+	idx := bytes.Index(r.buf[:r.readToIdx], []byte(crlf))
+	if idx > max || (idx == -1 && r.readToIdx > max) {
+		return newError(414, ErrRequestLineTooLong, "")
+	}
+	return nil
+}
+
+// checkHeaders enforces the header size and count limits. While the header
+// section is incomplete, the unparsed bytes in the buffer belong to it too.
+func (r *Reader) checkHeaders(req *Request) error {
+	if max := r.Limits.MaxHeaderCount; max > 0 && req.headerCount > max {
+		return newError(431, ErrHeaderTooLarge, "more than %d fields", max)
+	}
+	max := r.Limits.MaxHeaderBytes
+	if max <= 0 || req.state == readerStateInitialized {
+		return nil
+	}
+	size := req.headerBytes
+	if req.state != readerStateDone {
+		size += r.readToIdx
+	}
+	if size > max {
+		return newError(431, ErrHeaderTooLarge, "more than %d bytes", max)
+	}
+	return nil
+}
+
+// fill reads more data from the connection into the buffer, growing it when
+// it is full.
+func (r *Reader) fill() error {
+	if r.readToIdx >= len(r.buf) {
+		newBuf := make([]byte, len(r.buf)*2)
+		copy(newBuf, r.buf)
+		r.buf = newBuf
+	}
+
+	numBytesRead, err := r.reader.Read(r.buf[r.readToIdx:])
+	r.readToIdx += numBytesRead
+	if err != nil && numBytesRead == 0 {
+		return err
+	}
+	return nil
+}
+
+// readLine returns the next CRLF terminated line without the CRLF. Lines
+// longer than max bytes are rejected.
+func (r *Reader) readLine(max int) ([]byte, error) {
+	for {
+		idx := bytes.Index(r.buf[:r.readToIdx], []byte(crlf))
+		if idx > max || (idx == -1 && r.readToIdx > max) {
+			return nil, newError(400, ErrMalformedBody, "line over %d bytes", max)
+		}
+		if idx != -1 {
+			line := bytes.Clone(r.buf[:idx])
+			r.consume(idx + len(crlf))
+			return line, nil
+		}
+		if err := r.fill(); err != nil {
 			return nil, err
 		}
+	}
+}
 
-		copy(buf, buf[numBytesParsed:])
-		readToIdx -= numBytesParsed
+// consume drops n parsed bytes from the front of the buffer.
+func (r *Reader) consume(n int) {
+	copy(r.buf, r.buf[n:r.readToIdx])
+	r.readToIdx -= n
+}
+
+// read fills p with buffered bytes first and only then from the connection.
+func (r *Reader) read(p []byte) (int, error) {
+	if r.readToIdx > 0 {
+		n := copy(p, r.buf[:r.readToIdx])
+		r.consume(n)
+		return n, nil
 	}
-	return req, nil
+	return r.reader.Read(p)
 }
 
-func parseRequestLine(data []byte) (*RequestLine, int, error) {
+// BodyBytes reads the rest of the body into memory and returns it. It is a
+// convenience for handlers expecting small bodies; the result is cached and
+// Body is reset so it can be read again.
+func (r *Request) BodyBytes() ([]byte, error) {
+	if r.bodyBytes != nil {
+		return r.bodyBytes, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.bodyBytes = data
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
 
+// DiscardBody drains up to max unread body bytes off the connection so the
+// next request can be read. It fails if more than max bytes remain, in which
+// case the connection should be closed instead.
+func (r *Request) DiscardBody(max int64) error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.discard(max)
+}
+
+// KeepAlive reports whether the client allows the connection to be reused
+// after this request. HTTP/1.1 connections persist unless the client sends
+// "Connection: close", HTTP/1.0 ones only with "Connection: keep-alive".
+func (r *Request) KeepAlive() bool {
+	if r.Headers.HasToken("Connection", "close") {
+		return false
+	}
+	if r.RequestLine.HttpVersion == "1.0" {
+		return r.Headers.HasToken("Connection", "keep-alive")
+	}
+	return true
+}
+
+// PathValue returns the value captured for the named wildcard of the route
+// that matched the request, or "" if there is none.
+func (r *Request) PathValue(name string) string {
+	return r.pathValues[name]
+}
+
+// SetPathValue records a captured route wildcard. It is called by routers.
+func (r *Request) SetPathValue(name, value string) {
+	if r.pathValues == nil {
+		r.pathValues = make(map[string]string)
+	}
+	r.pathValues[name] = value
+}
+
+func parseRequestLine(data []byte) (*RequestLine, int, error) {
+	// Fail on a bare LF as soon as it arrives rather than waiting for a
+	// CRLF that a client using LF line endings will never send.
+	if lf := bytes.IndexByte(data, '\n'); lf == 0 || (lf > 0 && data[lf-1] != '\r') {
+		return nil, 0, newError(400, ErrMalformedRequestLine, "bare LF")
+	}
 	idx := bytes.Index(data, []byte(crlf))
 	if idx == -1 {
 		return nil, 0, nil
 	}
+	if idx == 0 {
+		// RFC 9112 2.2: ignore empty lines received before the request-line.
+		return nil, len(crlf), nil
+	}
 	requestLineText := string(data[:idx])
 	requestLine, err := requestLineFromString(requestLineText)
 	if err != nil {
@@ -89,36 +340,61 @@ func parseRequestLine(data []byte) (*RequestLine, int, error) {
 }
 
 func requestLineFromString(str string) (*RequestLine, error) {
+	if strings.ContainsAny(str, "\r\n") {
+		return nil, newError(400, ErrMalformedRequestLine, "bare CR or LF")
+	}
 	splitRequest := strings.Split(str, " ")
 	if len(splitRequest) != 3 {
-		return nil, fmt.Errorf("invalid number of header lines in: %s", splitRequest)
+		return nil, newError(400, ErrMalformedRequestLine, "invalid number of parts in: %q", str)
 	}
 
 	method := splitRequest[0]
+	if method == "" {
+		return nil, newError(400, ErrMalformedRequestLine, "empty method")
+	}
 
 	for _, char := range method {
 		if char < 'A' || char > 'Z' {
-			return nil, fmt.Errorf("invalid method: %s", method)
+			return nil, newError(400, ErrMalformedRequestLine, "invalid method: %q", method)
 		}
 	}
 
 	requestTarget := splitRequest[1]
+	if requestTarget == "" {
+		return nil, newError(400, ErrMalformedRequestLine, "empty request target")
+	}
+	for _, char := range requestTarget {
+		if char <= ' ' || char == 0x7f {
+			return nil, newError(400, ErrMalformedRequestLine, "invalid character in request target: %q", char)
+		}
+	}
 
-	httpVersionParts := strings.Split(splitRequest[2], "/")
-	if string(httpVersionParts[0]) != "HTTP" {
-		return nil, fmt.Errorf("invalid protocol: %s", httpVersionParts[1])
+	protocol, version, found := strings.Cut(splitRequest[2], "/")
+	if !found || protocol != "HTTP" {
+		return nil, newError(400, ErrMalformedRequestLine, "invalid protocol: %q", splitRequest[2])
 	}
-	if string(httpVersionParts[1]) != "1.1" {
-		return nil, fmt.Errorf("invalid HTTP version: %s", httpVersionParts[1])
+	if !validVersion(version) {
+		return nil, newError(400, ErrMalformedRequestLine, "invalid HTTP version: %q", version)
+	}
+	if version != "1.0" && version != "1.1" {
+		return nil, newError(505, ErrUnsupportedVersion, "%q", version)
 	}
 
 	return &RequestLine{
-		Method:        string(method),
-		RequestTarget: string(requestTarget),
-		HttpVersion:   string(httpVersionParts[1]),
+		Method:        method,
+		RequestTarget: requestTarget,
+		HttpVersion:   version,
 	}, nil
 }
 
+// validVersion checks the DIGIT "." DIGIT syntax of an HTTP version.
+func validVersion(version string) bool {
+	return len(version) == 3 &&
+		version[0] >= '0' && version[0] <= '9' &&
+		version[1] == '.' &&
+		version[2] >= '0' && version[2] <= '9'
+}
+
 func (r *Request) parse(data []byte) (int, error) {
 	total := 0
 	for r.state != readerStateDone {
@@ -143,42 +419,28 @@ func (r *Request) parseSingle(data []byte) (int, error) {
 		if err != nil {
 			return 0, err
 		}
-		if n == 0 {
-			return 0, nil
+		if requestLine == nil {
+			return n, nil
+		}
+		url, err := parseTarget(requestLine.Method, requestLine.RequestTarget)
+		if err != nil {
+			return 0, err
 		}
 		r.RequestLine = *requestLine
+		r.URL = url
 		r.state = readerStateParsingHeaders
 		return n, nil
 
 	case readerStateParsingHeaders:
 		n, done, err := r.Headers.Parse(data)
 		if err != nil {
-			return 0, err
+			return 0, newError(400, ErrInvalidHeader, "%v", err)
 		}
+		r.headerBytes += n
 		if done {
-			r.state = readerStateParsingBody
-		}
-		return n, nil
-	case readerStateParsingBody:
-		n := len(data)
-		contentLenStr, ok := r.Headers.Get("Content-Length")
-		if !ok {
-			r.state = readerStateDone
-			return n, nil
-		}
-
-		contentLength, err := strconv.Atoi(contentLenStr)
-		if err != nil {
-			return 0, fmt.Errorf("Invalid Content-Length: %s", err)
-		}
-		r.Body = append(r.Body, data...)
-		r.bodyLengthRead += n
-
-		if r.bodyLengthRead > contentLength {
-			return 0, fmt.Errorf("Content-Length too large")
-		}
-		if r.bodyLengthRead == contentLength {
 			r.state = readerStateDone
+		} else if n > 0 {
+			r.headerCount++
 		}
 		return n, nil
 
@@ -2,8 +2,11 @@ package request
 
 import (
 	"io"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -22,7 +25,7 @@ func TestBodyParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "hello world!\n", string(r.Body))
+	assert.Equal(t, "hello world!\n", bodyString(t, r))
 
 	// Test: Empty body and no content-length header
 	reader = &chunkReader{
@@ -34,7 +37,7 @@ func TestBodyParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "", string(r.Body))
+	assert.Equal(t, "", bodyString(t, r))
 
 	//Test Empty body content-length = 0
 	reader = &chunkReader{
@@ -47,7 +50,7 @@ func TestBodyParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "", string(r.Body))
+	assert.Equal(t, "", bodyString(t, r))
 
 	// Test: Body shorter than reported content length
 	reader = &chunkReader{
@@ -72,7 +75,7 @@ func TestBodyParse(t *testing.T) {
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "", string(r.Body))
+	assert.Equal(t, "", bodyString(t, r))
 }
 
 func TestHeaderParse(t *testing.T) {
@@ -84,19 +87,17 @@ func TestHeaderParse(t *testing.T) {
 	r, err := RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:42069", r.Headers["host"])
-	assert.Equal(t, "curl/7.81.0", r.Headers["user-agent"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:42069"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"curl/7.81.0"}, r.Headers.Values("user-agent"))
+	assert.Equal(t, []string{"*/*"}, r.Headers.Values("accept"))
 
-	// Test: Empty Headers
+	// Test: Empty Headers (HTTP/1.1 requires Host)
 	reader = &chunkReader{
 		data:            "GET / HTTP/1.1\r\n\r\n\r\n",
 		numBytesPerRead: 3,
 	}
 	r, err = RequestFromReader(reader)
-	require.NoError(t, err)
-	require.NotNil(t, r)
-	assert.Empty(t, r.Headers)
+	require.ErrorIs(t, err, ErrInvalidHost)
 
 	// Test: Malformed Header
 	reader = &chunkReader{
@@ -108,14 +109,22 @@ func TestHeaderParse(t *testing.T) {
 
 	// Test: Duplicate Headers
 	reader = &chunkReader{
-		data:            "GET / HTTP/1.1\r\nHost: localhost:6767\r\nHost: localhost:6969\r\nAccept: */*\r\n\r\n",
+		data:            "GET / HTTP/1.1\r\nHost: localhost:6767\r\nAccept: text/html\r\nAccept: */*\r\n\r\n",
 		numBytesPerRead: 8,
 	}
 	r, err = RequestFromReader(reader)
 	require.NoError(t, err)
 	require.NotNil(t, r)
-	assert.Equal(t, "localhost:6767, localhost:6969", r.Headers["host"])
-	assert.Equal(t, "*/*", r.Headers["accept"])
+	assert.Equal(t, []string{"localhost:6767"}, r.Headers.Values("host"))
+	assert.Equal(t, []string{"text/html", "*/*"}, r.Headers.Values("accept"))
+
+	// Test: Duplicate Host is rejected
+	reader = &chunkReader{
+		data:            "GET / HTTP/1.1\r\nHost: localhost:6767\r\nHost: localhost:6969\r\n\r\n",
+		numBytesPerRead: 8,
+	}
+	_, err = RequestFromReader(reader)
+	require.ErrorIs(t, err, ErrInvalidHost)
 
 	// Test: Missing End of Headrs
 	reader = &chunkReader{
@@ -182,6 +191,481 @@ func TestRequestLineParse(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestReaderMultipleRequests(t *testing.T) {
+	// Test: Pipelined requests share the reader's buffer
+	reader := NewReader(&chunkReader{
+		data: "POST /first HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 5\r\n" +
+			"\r\n" +
+			"hello" +
+			"GET /second HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Connection: close\r\n" +
+			"\r\n",
+		numBytesPerRead: 7,
+	})
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/first", r.RequestLine.RequestTarget)
+	assert.Equal(t, "hello", bodyString(t, r))
+	assert.True(t, r.KeepAlive())
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/second", r.RequestLine.RequestTarget)
+	assert.False(t, r.KeepAlive())
+
+	// Test: Clean EOF between requests
+	_, err = reader.ReadRequest()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func bodyString(t *testing.T, r *Request) string {
+	t.Helper()
+	body, err := r.BodyBytes()
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestStreamingBody(t *testing.T) {
+	body := strings.Repeat("0123456789", 100)
+	reader := NewReader(&chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Content-Length: 1000\r\n" +
+			"\r\n" +
+			body +
+			"GET /next HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"\r\n",
+		numBytesPerRead: 64,
+	})
+
+	// Test: Body is read in pieces as the handler consumes it
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	part := make([]byte, 10)
+	_, err = io.ReadFull(r.Body, part)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(part))
+
+	// Test: The next request can't be read while the body is pending
+	_, err = reader.ReadRequest()
+	require.Error(t, err)
+
+	// Test: Discarding the rest frees the connection for the next request
+	require.Error(t, r.DiscardBody(100))
+	require.NoError(t, r.DiscardBody(1000))
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", r.RequestLine.RequestTarget)
+
+	// Test: A closed body can't be read anymore
+	require.NoError(t, r.Body.Close())
+	_, err = r.Body.Read(part)
+	require.ErrorIs(t, err, ErrBodyClosed)
+}
+
+func TestChunkedBody(t *testing.T) {
+	// Test: Chunks with extensions and trailers, followed by another request
+	reader := NewReader(&chunkReader{
+		data: "POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"Trailer: X-Checksum\r\n" +
+			"\r\n" +
+			"5\r\nhello\r\n" +
+			"7;name=value;flag\r\n, world\r\n" +
+			"A \r\n from nyc\n\r\n" +
+			"0\r\n" +
+			"X-Checksum: abc123\r\n" +
+			"\r\n" +
+			"GET /next HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"\r\n",
+		numBytesPerRead: 3,
+	})
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world from nyc\n", bodyString(t, r))
+	checksum, ok := r.Trailers.Get("X-Checksum")
+	assert.True(t, ok)
+	assert.Equal(t, "abc123", checksum)
+
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/next", r.RequestLine.RequestTarget)
+
+	// Test: Empty chunked body
+	r, err = RequestFromReader(strings.NewReader("POST /upload HTTP/1.1\r\n" +
+		"Host: localhost:42069\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"\r\n" +
+		"0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "", bodyString(t, r))
+
+	// Test: Malformed chunks
+	malformed := []string{
+		"zz\r\nhello\r\n0\r\n\r\n",                // size is not hex
+		"\r\nhello\r\n0\r\n\r\n",                  // missing size
+		"5\r\nhelloXX\r\n0\r\n\r\n",               // data longer than size
+		"5;\r\nhello\r\n0\r\n\r\n",                // empty extension
+		"5\r\nhello\r\n",                          // no last chunk
+		"10000000000000000\r\nhello\r\n0\r\n\r\n", // size overflows
+	}
+	for _, body := range malformed {
+		_, err = RequestFromReader(strings.NewReader("POST /upload HTTP/1.1\r\n" +
+			"Host: localhost:42069\r\n" +
+			"Transfer-Encoding: chunked\r\n" +
+			"\r\n" + body))
+		require.Error(t, err, body)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	limits := Limits{
+		MaxRequestLineBytes: 32,
+		MaxHeaderBytes:      64,
+		MaxHeaderCount:      3,
+		MaxBodyBytes:        10,
+	}
+	read := func(data string) (*Request, error) {
+		reader := NewReader(&chunkReader{data: data, numBytesPerRead: 5})
+		reader.Limits = limits
+		return reader.ReadRequest()
+	}
+
+	// Test: Within every limit
+	_, err := read("POST /ok HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\n0123456789")
+	require.NoError(t, err)
+
+	// Test: Request line too long, with and without its CRLF received
+	_, err = read("GET /" + strings.Repeat("a", 40) + " HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.ErrorIs(t, err, ErrRequestLineTooLong)
+	_, err = read("GET /" + strings.Repeat("a", 40))
+	require.ErrorIs(t, err, ErrRequestLineTooLong)
+
+	// Test: Header section too large
+	_, err = read("GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 60) + "\r\n\r\n")
+	require.ErrorIs(t, err, ErrHeaderTooLarge)
+
+	// Test: Too many header lines
+	_, err = read("GET / HTTP/1.1\r\nHost: h\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\n")
+	require.ErrorIs(t, err, ErrHeaderTooLarge)
+
+	// Test: Declared body too large is rejected up front
+	_, err = read("POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 11\r\n\r\n01234567890")
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+
+	// Test: Chunked body too large is rejected while reading
+	r, err := read("POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		"6\r\n012345\r\n6\r\n012345\r\n0\r\n\r\n")
+	require.NoError(t, err)
+	_, err = io.ReadAll(r.Body)
+	require.ErrorIs(t, err, ErrBodyTooLarge)
+}
+
+func TestMessageFraming(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		err     error
+	}{
+		{"duplicate content-length", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 5\r\nContent-Length: 5\r\n\r\nhello", ErrInvalidContentLength},
+		{"conflicting content-length", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 5, 7\r\n\r\nhello", ErrInvalidContentLength},
+		{"signed content-length", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: +5\r\n\r\nhello", ErrInvalidContentLength},
+		{"negative content-length", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: -1\r\n\r\n", ErrInvalidContentLength},
+		{"empty content-length", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length:\r\n\r\n", ErrInvalidContentLength},
+		{"cl and te", "POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", ErrAmbiguousFraming},
+		{"unknown coding", "POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", ErrUnsupportedTransferCoding},
+		{"obfuscated chunked", "POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: xchunked\r\n\r\n", ErrUnsupportedTransferCoding},
+		{"chunked twice", "POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: chunked, chunked\r\n\r\n", nil},
+		{"missing host", "GET / HTTP/1.1\r\nAccept: */*\r\n\r\n", ErrInvalidHost},
+		{"bare lf in header", "GET / HTTP/1.1\r\nHost: h\nX-Smuggle: 1\r\n\r\n", nil},
+		{"bare cr in header", "GET / HTTP/1.1\r\nHost: h\rX-Smuggle: 1\r\n\r\n", nil},
+		{"bare lf in request line", "GET / HTTP/1.1\nHost: h\r\n\r\n", nil},
+		{"lf line endings", "GET / HTTP/1.1\nHost: h\n\n", ErrMalformedRequestLine},
+		{"lf ending headers", "GET / HTTP/1.1\r\nHost: h\n\n", ErrInvalidHeader},
+		{"space before colon", "GET / HTTP/1.1\r\nHost: h\r\nContent-Length : 5\r\n\r\nhello", nil},
+		{"control char in target", "GET /a\x00b HTTP/1.1\r\nHost: h\r\n\r\n", nil},
+		{"missing version", "GET / HTTP\r\nHost: h\r\n\r\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RequestFromReader(strings.NewReader(tt.request))
+			require.Error(t, err)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+
+	// Test: Transfer-Encoding is matched case-insensitively
+	r, err := RequestFromReader(strings.NewReader("POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: Chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", bodyString(t, r))
+}
+
+func TestHTTP10(t *testing.T) {
+	// Test: HTTP/1.0 request without Host
+	r, err := RequestFromReader(strings.NewReader("GET /old HTTP/1.0\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "1.0", r.RequestLine.HttpVersion)
+	assert.False(t, r.KeepAlive())
+
+	// Test: HTTP/1.0 opting into keep-alive
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, r.KeepAlive())
+
+	// Test: HTTP/1.1 persists unless the client closes
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: h\r\n\r\n"))
+	require.NoError(t, err)
+	assert.True(t, r.KeepAlive())
+	r, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: h\r\nConnection: close\r\n\r\n"))
+	require.NoError(t, err)
+	assert.False(t, r.KeepAlive())
+
+	// Test: HTTP/1.0 with Content-Length body
+	r, err = RequestFromReader(strings.NewReader("POST / HTTP/1.0\r\nContent-Length: 5\r\n\r\nhello"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", bodyString(t, r))
+}
+
+func TestRequestTarget(t *testing.T) {
+	tests := []struct {
+		method, target, host string
+		want                 URL
+	}{
+		{"GET", "/a/b?x=1&y=2", "h", URL{Form: OriginForm, Path: "/a/b", RawPath: "/a/b", RawQuery: "x=1&y=2"}},
+		{"GET", "/a%20b/c%2fd", "h", URL{Form: OriginForm, Path: "/a b/c/d", RawPath: "/a%20b/c%2Fd"}},
+		{"GET", "/a/./b/../../c/", "h", URL{Form: OriginForm, Path: "/c/", RawPath: "/c/"}},
+		{"GET", "/../../etc", "h", URL{Form: OriginForm, Path: "/etc", RawPath: "/etc"}},
+		{"GET", "/a/%2E%2e/b", "h", URL{Form: OriginForm, Path: "/b", RawPath: "/b"}},
+		{"GET", "/a/..", "h", URL{Form: OriginForm, Path: "/", RawPath: "/"}},
+		{"GET", "/s/..%2F..%2Fetc", "h", URL{Form: OriginForm, Path: "/s/../../etc", RawPath: "/s/..%2F..%2Fetc"}},
+		{"GET", "/%7Euser", "h", URL{Form: OriginForm, Path: "/~user", RawPath: "/~user"}},
+		{"GET", "HTTP://example.com:8080/x?q", "example.com:8080",
+			URL{Form: AbsoluteForm, Scheme: "http", Host: "example.com:8080", Path: "/x", RawPath: "/x", RawQuery: "q"}},
+		{"GET", "https://example.com", "example.com",
+			URL{Form: AbsoluteForm, Scheme: "https", Host: "example.com", Path: "/", RawPath: "/"}},
+		{"CONNECT", "example.com:443", "example.com:443", URL{Form: AuthorityForm, Host: "example.com:443"}},
+		{"CONNECT", "[::1]:443", "[::1]:443", URL{Form: AuthorityForm, Host: "[::1]:443"}},
+		{"OPTIONS", "*", "h", URL{Form: AsteriskForm}},
+	}
+	for _, tt := range tests {
+		r, err := RequestFromReader(strings.NewReader(
+			tt.method + " " + tt.target + " HTTP/1.1\r\nHost: h\r\n\r\n"))
+		require.NoError(t, err, tt.target)
+		assert.Equal(t, tt.want, *r.URL, tt.target)
+		assert.Equal(t, tt.host, r.Host, tt.target)
+	}
+
+	// Test: RequestURI rebuilds the normalized target
+	r, err := RequestFromReader(strings.NewReader("GET http://h/a/../b?c=d HTTP/1.1\r\nHost: h\r\n\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "/b?c=d", r.URL.RequestURI())
+
+	// Test: Invalid targets
+	for _, line := range []string{
+		"GET /a#frag HTTP/1.1",
+		"GET /a%zz HTTP/1.1",
+		"GET /a% HTTP/1.1",
+		"GET * HTTP/1.1",
+		"GET example.com HTTP/1.1",
+		"GET ftp://example.com/ HTTP/1.1",
+		"GET http:///x HTTP/1.1",
+		"GET http://user@example.com/ HTTP/1.1",
+		"CONNECT example.com HTTP/1.1",
+		"CONNECT /x HTTP/1.1",
+	} {
+		_, err := RequestFromReader(strings.NewReader(line + "\r\nHost: h\r\n\r\n"))
+		assert.ErrorIs(t, err, ErrInvalidTarget, line)
+	}
+
+	// Test: Malformed Host header
+	_, err = RequestFromReader(strings.NewReader("GET / HTTP/1.1\r\nHost: a b/c\r\n\r\n"))
+	assert.ErrorIs(t, err, ErrInvalidHost)
+}
+
+func TestQueryAndForm(t *testing.T) {
+	// Test: Query with repeated keys, plus signs and escapes
+	r, err := NewReader(strings.NewReader(
+		"GET /search?q=a+b%26c&tag=x&tag=y&empty=&flag&&%E2%9C%93=1 HTTP/1.1\r\nHost: h\r\n\r\n")).ReadRequest()
+	require.NoError(t, err)
+	query, err := r.Query()
+	require.NoError(t, err)
+	assert.Equal(t, "a b&c", query.Get("q"))
+	assert.Equal(t, []string{"x", "y"}, query["tag"])
+	assert.True(t, query.Has("empty"))
+	assert.True(t, query.Has("flag"))
+	assert.False(t, query.Has("missing"))
+	assert.Equal(t, "1", query.Get("✓"))
+
+	// Test: Urlencoded body, which stays readable afterwards
+	body := "name=J%C3%BCrgen+M&role=admin&role=ops"
+	r, err = NewReader(strings.NewReader(
+		"POST /users?role=guest HTTP/1.1\r\nHost: h\r\n" +
+			"Content-Type: application/x-www-form-urlencoded; charset=utf-8\r\n" +
+			"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body)).ReadRequest()
+	require.NoError(t, err)
+	form, err := r.Form()
+	require.NoError(t, err)
+	assert.Equal(t, "Jürgen M", form.Get("name"))
+	assert.Equal(t, []string{"admin", "ops"}, form["role"])
+	assert.Equal(t, body, bodyString(t, r))
+	query, err = r.Query()
+	require.NoError(t, err)
+	assert.Equal(t, "guest", query.Get("role"))
+
+	// Test: Other content types have an empty form and an untouched body
+	r, err = NewReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nHost: h\r\nContent-Type: text/plain\r\nContent-Length: 3\r\n\r\na=b")).ReadRequest()
+	require.NoError(t, err)
+	form, err = r.Form()
+	require.NoError(t, err)
+	assert.Empty(t, form)
+	assert.Equal(t, "a=b", bodyString(t, r))
+
+	// Test: Invalid escape
+	r, err = NewReader(strings.NewReader("GET /?a=%zz HTTP/1.1\r\nHost: h\r\n\r\n")).ReadRequest()
+	require.NoError(t, err)
+	_, err = r.Query()
+	assert.ErrorIs(t, err, ErrMalformedForm)
+
+	// Test: Form size and field limits
+	reader := NewReader(strings.NewReader(
+		"POST / HTTP/1.1\r\nHost: h\r\nContent-Type: application/x-www-form-urlencoded\r\n" +
+			"Content-Length: 11\r\n\r\na=123456789"))
+	reader.Limits.MaxFormBytes = 10
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	_, err = r.Form()
+	var reqErr *Error
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, 413, reqErr.StatusCode)
+
+	reader = NewReader(strings.NewReader("GET /?a&b&c HTTP/1.1\r\nHost: h\r\n\r\n"))
+	reader.Limits.MaxFormFields = 2
+	r, err = reader.ReadRequest()
+	require.NoError(t, err)
+	_, err = r.Query()
+	assert.ErrorIs(t, err, ErrMalformedForm)
+}
+
+func TestMultipartForm(t *testing.T) {
+	body := "--b\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nvalue\r\n" +
+		"--b\r\nContent-Disposition: form-data; name=\"f\"; filename=\"f.bin\"\r\n\r\n0123456789\r\n" +
+		"--b--\r\n"
+	newRequest := func(contentType string, limits Limits) *Request {
+		reader := NewReader(strings.NewReader(
+			"POST / HTTP/1.1\r\nHost: h\r\nContent-Type: " + contentType + "\r\n" +
+				"Content-Length: " + strconv.Itoa(len(body)) + "\r\n\r\n" + body))
+		reader.Limits = limits
+		r, err := reader.ReadRequest()
+		require.NoError(t, err)
+		return r
+	}
+
+	// Test: Fields in memory, the file spooled past the memory limit
+	limits := DefaultLimits()
+	limits.MaxMultipartMemory = 8
+	r := newRequest(`multipart/form-data; boundary="b"`, limits)
+	form, err := r.MultipartForm()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"value"}, form.Value["name"])
+	require.Len(t, form.File["f"], 1)
+	assert.Equal(t, int64(10), form.File["f"][0].Size)
+	cached, err := r.MultipartForm()
+	require.NoError(t, err)
+	assert.Same(t, form, cached)
+	require.NoError(t, r.Cleanup())
+
+	// Test: Not multipart
+	r = newRequest("text/plain", DefaultLimits())
+	_, err = r.MultipartForm()
+	assert.ErrorIs(t, err, ErrMalformedForm)
+	r = newRequest("multipart/form-data", DefaultLimits())
+	_, err = r.MultipartReader()
+	assert.ErrorIs(t, err, ErrMalformedForm)
+
+	// Test: Fields over the memory limit are a 413
+	limits.MaxMultipartMemory = 2
+	r = newRequest("multipart/form-data; boundary=b", limits)
+	_, err = r.MultipartForm()
+	var reqErr *Error
+	require.ErrorAs(t, err, &reqErr)
+	assert.Equal(t, 413, reqErr.StatusCode)
+}
+
+func TestObsFold(t *testing.T) {
+	data := "GET / HTTP/1.1\r\nHost: h\r\nX-Long: a\r\n b\r\n\r\n"
+
+	// Test: Folded headers are rejected by default
+	_, err := NewReader(strings.NewReader(data)).ReadRequest()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+
+	// Test: Folded headers are joined when enabled
+	reader := NewReader(strings.NewReader(data))
+	reader.UnfoldObsFold = true
+	r, err := reader.ReadRequest()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a b"}, r.Headers.Values("X-Long"))
+
+	// Test: Whitespace before the first field is never a fold
+	reader = NewReader(strings.NewReader("GET / HTTP/1.1\r\n Host: h\r\n\r\n"))
+	reader.UnfoldObsFold = true
+	_, err = reader.ReadRequest()
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestBodyTimeout(t *testing.T) {
+	// Test: A read deadline during the body is a 408, for both framings
+	for _, head := range []string{
+		"Content-Length: 10\r\n",
+		"Transfer-Encoding: chunked\r\n",
+	} {
+		conn := io.MultiReader(
+			strings.NewReader("POST /upload HTTP/1.1\r\nHost: localhost\r\n"+head+"\r\n"),
+			iotest.ErrReader(os.ErrDeadlineExceeded),
+		)
+		r, err := NewReader(conn).ReadRequest()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r.Body)
+		var reqErr *Error
+		require.ErrorAs(t, err, &reqErr, head)
+		assert.ErrorIs(t, err, ErrTimeout)
+		assert.Equal(t, 408, reqErr.StatusCode)
+	}
+}
+
+func TestErrorStatusCodes(t *testing.T) {
+	tests := []struct {
+		request string
+		kind    error
+		status  int
+	}{
+		{"GET /\r\nHost: h\r\n\r\n", ErrMalformedRequestLine, 400},
+		{"GET / HTTP/2.0\r\nHost: h\r\n\r\n", ErrUnsupportedVersion, 505},
+		{"GET / HTTP/1.2\r\nHost: h\r\n\r\n", ErrUnsupportedVersion, 505},
+		{"GET / HTTP/1\r\nHost: h\r\n\r\n", ErrMalformedRequestLine, 400},
+		{"POST / HTTP/1.0\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n", ErrInvalidHeader, 400},
+		{"GET / HTTP/1.1\r\nH@st: h\r\n\r\n", ErrInvalidHeader, 400},
+		{"GET / HTTP/1.1\r\nHost: h\r\n", ErrIncomplete, 400},
+		{"POST / HTTP/1.1\r\nHost: h\r\nTransfer-Encoding: gzip\r\n\r\n", ErrUnsupportedTransferCoding, 501},
+		{"POST / HTTP/1.1\r\nHost: h\r\nContent-Length: 99999999999\r\n\r\n", ErrBodyTooLarge, 413},
+	}
+	for _, tt := range tests {
+		_, err := RequestFromReader(strings.NewReader(tt.request))
+		var reqErr *Error
+		require.ErrorAs(t, err, &reqErr, tt.request)
+		assert.ErrorIs(t, err, tt.kind)
+		assert.Equal(t, tt.status, reqErr.StatusCode, tt.request)
+	}
+}
+
 type chunkReader struct {
 	data            string
 	numBytesPerRead int
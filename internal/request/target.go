@@ -0,0 +1,245 @@
+package request
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TargetForm is the form a request-target was sent in, RFC 9112 3.2.
+type TargetForm int
+
+const (
+	OriginForm    TargetForm = iota // "/path?query", the usual form
+	AbsoluteForm                    // "http://host/path?query"
+	AuthorityForm                   // "host:port", only for CONNECT
+	AsteriskForm                    // "*", only for server-wide OPTIONS
+)
+
+// URL is a parsed request-target. Fragments are not part of a request-target
+// and are rejected.
+type URL struct {
+	Form TargetForm
+	// Scheme is set for absolute-form targets only, in lowercase.
+	Scheme string
+	// Host is the authority of absolute- and authority-form targets.
+	Host string
+	// RawPath is the escaped path with dot segments removed and Path is it
+	// percent-decoded. Since decoding comes last, an encoded "/" in RawPath
+	// can make "." or ".." segments reappear in Path, so split RawPath when
+	// the segments matter. Both are empty for authority- and asterisk-form
+	// targets.
+	Path    string
+	RawPath string
+	// RawQuery is the query without the leading "?", still escaped.
+	RawQuery string
+}
+
+// RequestURI returns the target in origin-form, or as sent for the
+// authority and asterisk forms.
+func (u *URL) RequestURI() string {
+	switch u.Form {
+	case AuthorityForm:
+		return u.Host
+	case AsteriskForm:
+		return "*"
+	}
+	if u.RawQuery != "" {
+		return u.RawPath + "?" + u.RawQuery
+	}
+	return u.RawPath
+}
+
+// parseTarget parses a request-target, checking that its form is allowed for
+// the method.
+func parseTarget(method, target string) (*URL, error) {
+	if strings.Contains(target, "#") {
+		return nil, newError(400, ErrInvalidTarget, "fragment in %q", target)
+	}
+
+	switch {
+	case target == "*":
+		if method != "OPTIONS" {
+			return nil, newError(400, ErrInvalidTarget, "asterisk-form with %s", method)
+		}
+		return &URL{Form: AsteriskForm}, nil
+
+	case method == "CONNECT":
+		host, port, found := cutPort(target)
+		if !found || port == "" || host == "" || !validHost(target) {
+			return nil, newError(400, ErrInvalidTarget, "invalid authority %q", target)
+		}
+		return &URL{Form: AuthorityForm, Host: target}, nil
+
+	case strings.HasPrefix(target, "/"):
+		u := &URL{Form: OriginForm}
+		if err := u.setPathQuery(target); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+
+	scheme, rest, found := strings.Cut(target, "://")
+	scheme = strings.ToLower(scheme)
+	if !found || (scheme != "http" && scheme != "https") {
+		return nil, newError(400, ErrInvalidTarget, "unsupported target %q", target)
+	}
+	host, pathQuery := rest, "/"
+	if i := strings.IndexAny(rest, "/?"); i != -1 {
+		host, pathQuery = rest[:i], rest[i:]
+		if pathQuery[0] == '?' {
+			pathQuery = "/" + pathQuery
+		}
+	}
+	if host == "" || !validHost(host) {
+		return nil, newError(400, ErrInvalidTarget, "invalid authority in %q", target)
+	}
+	u := &URL{Form: AbsoluteForm, Scheme: scheme, Host: host}
+	if err := u.setPathQuery(pathQuery); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// setPathQuery splits an origin-form target and normalizes its path.
+func (u *URL) setPathQuery(target string) error {
+	rawPath, rawQuery, _ := strings.Cut(target, "?")
+	escaped, err := normalizeEscapes(rawPath)
+	if err != nil {
+		return newError(400, ErrInvalidTarget, "%v in %q", err, target)
+	}
+	u.RawPath = removeDotSegments(escaped)
+	u.Path = unescape(u.RawPath)
+	u.RawQuery = rawQuery
+	return nil
+}
+
+// normalizeEscapes checks the percent-encodings in s, decodes those of
+// unreserved characters and uppercases the rest, RFC 3986 6.2.2. Decoding
+// first means "%2E%2E" is treated as the dot segment it stands for.
+func normalizeEscapes(s string) (string, error) {
+	if !strings.Contains(s, "%") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			return "", fmt.Errorf("invalid escape at offset %d", i)
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// unescape decodes a string whose escapes have already been validated.
+func unescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			b.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// removeDotSegments resolves "." and ".." segments in an absolute path,
+// RFC 3986 5.2.4. A ".." never climbs above the root.
+func removeDotSegments(path string) string {
+	segments := strings.Split(path[1:], "/")
+	out := make([]string, 0, len(segments))
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+			continue
+		}
+		// "/a/.." names the directory, so keep its trailing slash.
+		if last {
+			out = append(out, "")
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+// validHost checks the syntax of an authority without userinfo: a reg-name,
+// IPv4 address or bracketed IP literal with an optional numeric port.
+func validHost(authority string) bool {
+	host, port, _ := cutPort(authority)
+	for i := 0; i < len(port); i++ {
+		if port[i] < '0' || port[i] > '9' {
+			return false
+		}
+	}
+	if strings.HasPrefix(host, "[") {
+		if !strings.HasSuffix(host, "]") || len(host) < 3 {
+			return false
+		}
+		for _, c := range host[1 : len(host)-1] {
+			if !isHex(byte(c)) && c != ':' && c != '.' {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i < len(host); i++ {
+		c := host[i]
+		switch {
+		case isUnreserved(c), strings.IndexByte("!$&'()*+,;=", c) != -1:
+		case c == '%' && i+2 < len(host) && isHex(host[i+1]) && isHex(host[i+2]):
+			i += 2
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// cutPort splits "host:port", leaving the colons of an IP literal alone.
+func cutPort(authority string) (host, port string, found bool) {
+	i := strings.LastIndexByte(authority, ':')
+	if i == -1 || i < strings.LastIndexByte(authority, ']') {
+		return authority, "", false
+	}
+	return authority[:i], authority[i+1:], true
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
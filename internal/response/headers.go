@@ -1,14 +1,12 @@
 package response
 
 import (
-	"fmt"
 	"go-http/internal/headers"
 )
 
-func GetDefaultHeaders(contentLength int) headers.Headers {
+func GetDefaultHeaders(contentLength int) *headers.Headers {
 	h := headers.NewHeaders()
-	h.Set("Content-Length", fmt.Sprint(contentLength))
-	h.Set("Connection", "close")
-	h.Set("Content-Type", "text/plain")
+	h.SetContentLength(int64(contentLength))
+	h.SetContentType("text/plain", nil)
 	return h
 }
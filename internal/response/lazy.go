@@ -0,0 +1,193 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"go-http/internal/headers"
+)
+
+// bufferSize is how much of a body Write holds back before the header is
+// sent. A body that fits is sent with a Content-Length, a larger one chunked.
+const bufferSize = 4 << 10
+
+// ErrContentLength is returned when the body written does not match the
+// declared Content-Length. The response is aborted.
+var ErrContentLength = errors.New("body length does not match Content-Length")
+
+// Header returns the header fields sent with the response by Write, Flush or
+// Finish. They can be changed freely until the header is sent; changes after
+// that have no effect. It is independent of the headers passed to
+// WriteHeaders.
+func (w *Writer) Header() *headers.Headers {
+	if w.header == nil {
+		w.header = headers.NewHeaders()
+	}
+	return w.header
+}
+
+// Trailer returns the trailer fields Finish sends after a chunked body. They
+// are dropped if the body ends up with a Content-Length, so call Flush first
+// to make sure it is chunked.
+func (w *Writer) Trailer() *headers.Headers {
+	if w.trailer == nil {
+		w.trailer = headers.NewHeaders()
+	}
+	return w.trailer
+}
+
+// WriteHeader sets the status code sent with the header, which defaults to
+// 200. Only the first call counts. An informational 1xx status other than 101
+// is sent right away with the current Header instead, and the final status
+// can still be chosen afterwards.
+func (w *Writer) WriteHeader(statusCode StatusCode) error {
+	if w.state != writerStateStatusLine || w.pending != 0 || len(w.buf) > 0 {
+		return fmt.Errorf("status already set to %d", w.Status())
+	}
+	if err := validateStatusLine(statusCode, ""); err != nil {
+		return err
+	}
+	if statusCode < 200 && statusCode != StatusSwitchingProtocols {
+		if err := w.WriteStatusLine(statusCode); err != nil {
+			return err
+		}
+		return w.WriteHeaders(w.Header())
+	}
+	w.pending = statusCode
+	return nil
+}
+
+// Write writes p as part of the body. Unless Header declares the framing,
+// small bodies are buffered so Finish can send them with a Content-Length;
+// once the buffer overflows the header is sent with chunked encoding and
+// the data streamed from there on.
+func (w *Writer) Write(p []byte) (int, error) {
+	if len(p) > 0 && !bodyAllowed(w.pendingOrOK()) {
+		return 0, fmt.Errorf("status %d does not allow a body", w.pendingOrOK())
+	}
+	if w.state == writerStateStatusLine {
+		if !w.framed() {
+			if len(w.buf)+len(p) <= bufferSize {
+				w.buf = append(w.buf, p...)
+				return len(p), nil
+			}
+			w.Header().Set("Transfer-Encoding", "chunked")
+		}
+		if err := w.sendHeader(); err != nil {
+			return 0, err
+		}
+	}
+	return w.write(p)
+}
+
+// Flush sends the header and anything buffered right away, switching to
+// chunked encoding if Header declares no framing. Later writes are not
+// buffered.
+func (w *Writer) Flush() error {
+	if w.state != writerStateStatusLine {
+		return nil
+	}
+	if !w.framed() && bodyAllowed(w.pendingOrOK()) {
+		w.Header().Set("Transfer-Encoding", "chunked")
+	}
+	return w.sendHeader()
+}
+
+// Finish completes the response once the handler is done. An unsent header
+// goes out with a Content-Length for whatever was buffered, a chunked body is
+// terminated and followed by Trailer, and a body shorter than its declared
+// Content-Length aborts the response with ErrContentLength. The server calls
+// it once the handler has returned.
+func (w *Writer) Finish() error {
+	if w.aborted {
+		return nil
+	}
+	if w.state == writerStateStatusLine {
+		if !w.framed() && bodyAllowed(w.pendingOrOK()) {
+			w.Header().SetContentLength(int64(len(w.buf)))
+		}
+		if err := w.sendHeader(); err != nil {
+			return err
+		}
+	}
+	if w.state < writerStateBody {
+		return nil
+	}
+	if !w.chunked {
+		if w.contentLength >= 0 && w.bodyWritten < w.contentLength && bodyAllowed(w.status) && !w.head {
+			w.Abort()
+			return fmt.Errorf("%w: wrote %d of %d bytes", ErrContentLength, w.bodyWritten, w.contentLength)
+		}
+		return nil
+	}
+	// Chunked responses written with WriteChunkedBody are left to the
+	// handler to end.
+	if w.header == nil {
+		return nil
+	}
+	if w.state == writerStateBody {
+		if _, err := w.WriteChunkedBodyDone(); err != nil {
+			return err
+		}
+	}
+	if w.state == writerStateTrailers {
+		return w.WriteTrailers(w.Trailer())
+	}
+	return nil
+}
+
+// Reset drops the status, Header, Trailer and buffered body of a response
+// that has not started, so a different one can be written instead. It does
+// nothing once the response has started.
+func (w *Writer) Reset() {
+	if w.Started() {
+		return
+	}
+	w.header = nil
+	w.trailer = nil
+	w.pending = 0
+	w.buf = nil
+}
+
+// framed reports whether Header declares how the body is delimited.
+func (w *Writer) framed() bool {
+	h := w.Header()
+	return len(h.Values("Content-Length")) > 0 || len(h.Values("Transfer-Encoding")) > 0
+}
+
+// sendHeader writes the pending status line and Header, followed by the
+// buffered body.
+func (w *Writer) sendHeader() error {
+	if w.state != writerStateStatusLine {
+		return nil
+	}
+	buf := w.buf
+	if err := w.WriteStatusLine(w.pendingOrOK()); err != nil {
+		return err
+	}
+	if err := w.WriteHeaders(w.Header()); err != nil {
+		return err
+	}
+	_, err := w.write(buf)
+	return err
+}
+
+// write sends p in the framing chosen by the header.
+func (w *Writer) write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if !w.chunked {
+		return w.WriteBody(p)
+	}
+	if _, err := w.WriteChunkedBody(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *Writer) pendingOrOK() StatusCode {
+	if w.pending == 0 {
+		return StatusOK
+	}
+	return w.pending
+}
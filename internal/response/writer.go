@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go-http/internal/headers"
 	"io"
+	"strings"
 )
 
 type WriterState int
@@ -13,33 +14,167 @@ const (
 	writerStateStatusLine
 	writerStateBody
 	writerStateTrailers
+	writerStateDone
 )
 
 type Writer struct {
 	w     io.Writer
 	state WriterState
+
+	version       string
+	status        StatusCode
+	closeAfter    bool
+	head          bool
+	aborted       bool
+	chunked       bool
+	contentLength int
+	bodyWritten   int
+
+	// header, trailer, pending and buf back the Header, WriteHeader and
+	// Write API.
+	header  *headers.Headers
+	trailer *headers.Headers
+	pending StatusCode
+	buf     []byte
 }
 
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{
-		w:     w,
-		state: writerStateStatusLine,
+		w:             w,
+		state:         writerStateStatusLine,
+		version:       "1.1",
+		contentLength: -1,
 	}
 }
 
-func (w *Writer) WriteTrailers(h headers.Headers) error {
+// CloseAfterResponse marks the connection to be closed once this response is
+// written. WriteHeaders will send "Connection: close" to the client.
+func (w *Writer) CloseAfterResponse() {
+	w.closeAfter = true
+}
+
+// SetVersion sets the HTTP version of the client, "1.0" or "1.1". HTTP/1.0
+// clients get an HTTP/1.0 status line and, since they don't understand
+// chunked encoding, chunked bodies are sent raw and ended by closing the
+// connection.
+func (w *Writer) SetVersion(version string) {
+	w.version = version
+}
+
+// SetMethod sets the method of the request being answered. Responses to HEAD
+// keep their header fields, Content-Length included, but every body write is
+// dropped, RFC 9110 9.3.2.
+func (w *Writer) SetMethod(method string) {
+	w.head = method == "HEAD"
+}
+
+// chunkedRaw reports whether chunked writes have to be sent unframed.
+func (w *Writer) chunkedRaw() bool {
+	return w.chunked && w.version == "1.0"
+}
+
+// Status returns the status code of the response. Before the status line is
+// written it is the one Finish would send: the code passed to WriteHeader,
+// or 200.
+func (w *Writer) Status() StatusCode {
+	if w.state == writerStateStatusLine {
+		return w.pendingOrOK()
+	}
+	return w.status
+}
+
+// Started reports whether the final status line has reached the connection,
+// after which the response can no longer be replaced by another. Replacing
+// a response that has not started discards anything Write buffered.
+func (w *Writer) Started() bool {
+	return w.state != writerStateStatusLine
+}
+
+// Abort gives up on a response that has started but cannot be completed.
+// Finish leaves it unterminated and the connection is closed, so the client
+// can tell it is truncated.
+func (w *Writer) Abort() {
+	w.aborted = true
+	w.closeAfter = true
+}
+
+// BytesWritten returns the number of body bytes written so far, including
+// those still buffered and excluding chunked framing.
+func (w *Writer) BytesWritten() int {
+	return w.bodyWritten + len(w.buf)
+}
+
+// Wrap replaces the stream the response is written to with wrap applied to
+// it. Middleware can use it to observe or transform the raw output.
+func (w *Writer) Wrap(wrap func(io.Writer) io.Writer) {
+	w.w = wrap(w.w)
+}
+
+// KeepAlive reports whether the response was completely framed so the
+// connection can be reused for another request.
+func (w *Writer) KeepAlive() bool {
+	if w.closeAfter || w.aborted || w.state < writerStateBody {
+		return false
+	}
+	if !bodyAllowed(w.status) || w.head {
+		return true
+	}
+	if w.chunked {
+		return w.state == writerStateDone
+	}
+	if w.contentLength >= 0 {
+		return w.bodyWritten == w.contentLength
+	}
+	// No framing, the body is delimited by closing the connection.
+	return false
+}
+
+func (w *Writer) WriteTrailers(h *headers.Headers) error {
 	if w.state != writerStateTrailers {
 		return fmt.Errorf("invalid writer state for writing trailers: %d", w.state)
 	}
+	if err := validateFields(h); err != nil {
+		return err
+	}
+	if w.chunkedRaw() || w.head {
+		w.state = writerStateDone
+		return nil
+	}
 
-	for key, val := range h {
-		_, err := w.w.Write([]byte(fmt.Sprintf("%s: %s\r\n", key, val)))
-		if err != nil {
+	if err := w.writeFields(h, nil); err != nil {
+		return err
+	}
+	_, err := w.w.Write([]byte("\r\n"))
+	if err != nil {
+		return err
+	}
+	w.state = writerStateDone
+	return nil
+}
+
+// validateFields rejects fields that would corrupt the response if written,
+// before any of them are.
+func validateFields(h *headers.Headers) error {
+	for key, val := range h.All() {
+		if err := headers.ValidateField(key, val); err != nil {
 			return err
 		}
 	}
-	_, err := w.w.Write([]byte("\r\n"))
-	return err
+	return nil
+}
+
+// writeFields writes the field lines of h in order, leaving out those skip
+// returns true for.
+func (w *Writer) writeFields(h *headers.Headers, skip func(name string) bool) error {
+	for key, val := range h.All() {
+		if skip != nil && skip(key) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w.w, "%s: %s\r\n", key, val); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
@@ -49,6 +184,14 @@ func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	if w.state != writerStateBody {
 		return 0, fmt.Errorf("invlaid state for writing body: %d", w.state)
 	}
+	if w.head {
+		return len(p), nil
+	}
+	if w.chunkedRaw() {
+		n, err := w.w.Write(p)
+		w.bodyWritten += n
+		return n, err
+	}
 	chunkSize := len(p)
 
 	totalWrittenBytes := 0
@@ -60,6 +203,7 @@ func (w *Writer) WriteChunkedBody(p []byte) (int, error) {
 	totalWrittenBytes += n
 
 	n, err = w.w.Write(p)
+	w.bodyWritten += n
 	if err != nil {
 		return totalWrittenBytes, err
 	}
@@ -78,6 +222,10 @@ func (w *Writer) WriteChunkedBodyDone() (int, error) {
 	if w.state != writerStateBody {
 		return 0, fmt.Errorf("invlaid state for writing body: %d", w.state)
 	}
+	if w.chunkedRaw() || w.head {
+		w.state = writerStateTrailers
+		return 0, nil
+	}
 	n, err := w.w.Write([]byte("0\r\n"))
 	if err != nil {
 		return n, err
@@ -86,30 +234,104 @@ func (w *Writer) WriteChunkedBodyDone() (int, error) {
 	return n, nil
 }
 
+// WriteStatusLine writes the status line with the registered reason phrase
+// for statusCode. Codes outside the registry are sent with an empty phrase.
 func (w *Writer) WriteStatusLine(statusCode StatusCode) error {
+	return w.WriteStatusLineReason(statusCode, StatusText(statusCode))
+}
+
+// WriteStatusLineReason writes the status line with a custom reason phrase.
+// A 1xx status other than 101 is interim: after its headers another status
+// line can be written. Interim responses are dropped for HTTP/1.0 clients,
+// which must not be sent any, RFC 9110 15.2.
+func (w *Writer) WriteStatusLineReason(statusCode StatusCode, reason string) error {
 	if w.state != writerStateStatusLine {
 		return fmt.Errorf("invalid state for writing status line: %d", w.state)
 	}
+	if err := validateStatusLine(statusCode, reason); err != nil {
+		return err
+	}
 
 	defer func() { w.state = writerStateHeader }()
-	_, err := w.w.Write(getStatusLine(statusCode))
+	w.status = statusCode
+	w.buf = nil
+	if w.dropInterim() {
+		return nil
+	}
+	_, err := w.w.Write(getStatusLine(w.version, statusCode, reason))
 	return err
 }
 
-func (w *Writer) WriteHeaders(h headers.Headers) error {
+// interim reports whether the status line written is an interim response.
+func (w *Writer) interim() bool {
+	return w.status < 200 && w.status != StatusSwitchingProtocols
+}
+
+// dropInterim reports whether the interim response being written is
+// withheld from an HTTP/1.0 client.
+func (w *Writer) dropInterim() bool {
+	return w.interim() && w.version == "1.0"
+}
+
+func (w *Writer) WriteHeaders(h *headers.Headers) error {
 	if w.state != writerStateHeader {
 		return fmt.Errorf("invalid state for writing headers: %d", w.state)
 	}
+	if err := validateFields(h); err != nil {
+		return err
+	}
+	if w.interim() {
+		if w.dropInterim() {
+			w.state = writerStateStatusLine
+			return nil
+		}
+		if err := w.writeFields(h, nil); err != nil {
+			return err
+		}
+		w.state = writerStateStatusLine
+		_, err := w.w.Write([]byte("\r\n"))
+		return err
+	}
 
 	defer func() { w.state = writerStateBody }()
 
-	for key, val := range h {
-		_, err := w.w.Write([]byte(fmt.Sprintf("%s: %s\r\n", key, val)))
-		if err != nil {
+	if h.HasToken("Connection", "close") {
+		w.closeAfter = true
+	}
+	w.chunked = h.HasToken("Transfer-Encoding", "chunked")
+	if n, err := h.ContentLength(); err == nil && !w.chunked {
+		w.contentLength = int(n)
+	}
+	if w.version == "1.0" && w.contentLength < 0 && bodyAllowed(w.status) && !w.head {
+		// Without a length the body can only be ended by closing.
+		w.closeAfter = true
+	}
+
+	err := w.writeFields(h, func(name string) bool {
+		if strings.EqualFold(name, "connection") {
+			return true
+		}
+		return w.chunkedRaw() && (strings.EqualFold(name, "transfer-encoding") || strings.EqualFold(name, "trailer"))
+	})
+	if err != nil {
+		return err
+	}
+
+	// HTTP/1.1 connections persist unless told otherwise, HTTP/1.0 ones
+	// only when the response opts in.
+	connection, hasConnection := h.Get("Connection")
+	switch {
+	case w.closeAfter:
+		connection, hasConnection = "close", true
+	case w.version == "1.0":
+		connection, hasConnection = "keep-alive", true
+	}
+	if hasConnection {
+		if _, err := fmt.Fprintf(w.w, "Connection: %s\r\n", connection); err != nil {
 			return err
 		}
 	}
-	_, err := w.w.Write([]byte("\r\n"))
+	_, err = w.w.Write([]byte("\r\n"))
 	return err
 }
 
@@ -117,5 +339,17 @@ func (w *Writer) WriteBody(p []byte) (int, error) {
 	if w.state != writerStateBody {
 		return 0, fmt.Errorf("invalid state for writing body: %d", w.state)
 	}
-	return w.w.Write(p)
+	if len(p) > 0 && !bodyAllowed(w.status) {
+		return 0, fmt.Errorf("status %d does not allow a body", w.status)
+	}
+	if w.head {
+		return len(p), nil
+	}
+	if !w.chunked && w.contentLength >= 0 && w.bodyWritten+len(p) > w.contentLength {
+		w.Abort()
+		return 0, fmt.Errorf("%w: %d bytes over %d", ErrContentLength, w.bodyWritten+len(p)-w.contentLength, w.contentLength)
+	}
+	n, err := w.w.Write(p)
+	w.bodyWritten += n
+	return n, err
 }
@@ -0,0 +1,332 @@
+package response
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"go-http/internal/headers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteHeaders(t *testing.T) {
+	// Test: Canonical names in insertion order, repeated fields kept apart
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := GetDefaultHeaders(0)
+	h.Add("set-cookie", "a=1")
+	h.Add("SET-COOKIE", "b=2")
+	h.Set("etag", `"v1"`)
+	h.Set("www-authenticate", "Basic")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 0\r\n"+
+		"Content-Type: text/plain\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"Set-Cookie: b=2\r\n"+
+		"ETag: \"v1\"\r\n"+
+		"WWW-Authenticate: Basic\r\n"+
+		"\r\n", out.String())
+
+	// Test: Original casing when asked for
+	out.Reset()
+	w = NewWriter(&out)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h = headers.NewHeaders()
+	h.PreserveCase = true
+	h.Set("x-lower-case", "1")
+	require.NoError(t, w.WriteHeaders(h))
+	assert.Contains(t, out.String(), "\r\nx-lower-case: 1\r\n")
+
+	// Test: CR, LF and NUL are rejected before anything is written
+	for _, value := range []string{"a\r\nSet-Cookie: evil=1", "a\nb", "a\rb", "a\x00b"} {
+		out.Reset()
+		w = NewWriter(&out)
+		require.NoError(t, w.WriteStatusLine(StatusOK))
+		written := out.Len()
+		h = headers.NewHeaders()
+		h.Set("X-Ok", "1")
+		h.Set("X-Bad", value)
+		assert.Error(t, w.WriteHeaders(h), value)
+		assert.Equal(t, written, out.Len())
+	}
+
+	// Test: Invalid names are rejected
+	out.Reset()
+	w = NewWriter(&out)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h = headers.NewHeaders()
+	h.Set("X Bad:", "1")
+	assert.Error(t, w.WriteHeaders(h))
+}
+
+func TestWriteTrailers(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := headers.NewHeaders()
+	h.Set("transfer-encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err := w.WriteChunkedBodyDone()
+	require.NoError(t, err)
+
+	// Test: Injected trailers are rejected
+	trailers := headers.NewHeaders()
+	trailers.Set("x-checksum", "abc\r\n\r\nHTTP/1.1 200 OK")
+	assert.Error(t, w.WriteTrailers(trailers))
+
+	// Test: Canonical trailer names
+	trailers.Set("x-checksum", "abc")
+	require.NoError(t, w.WriteTrailers(trailers))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n0\r\nX-Checksum: abc\r\n\r\n", out.String())
+}
+
+func TestWriteStatusLine(t *testing.T) {
+	// Test: Registered reason phrases
+	for code, line := range map[StatusCode]string{
+		StatusCreated:                       "HTTP/1.1 201 Created\r\n",
+		StatusNoContent:                     "HTTP/1.1 204 No Content\r\n",
+		StatusMovedPermanently:              "HTTP/1.1 301 Moved Permanently\r\n",
+		StatusNotFound:                      "HTTP/1.1 404 Not Found\r\n",
+		StatusTooManyRequests:               "HTTP/1.1 429 Too Many Requests\r\n",
+		StatusNetworkAuthenticationRequired: "HTTP/1.1 511 Network Authentication Required\r\n",
+	} {
+		var out bytes.Buffer
+		require.NoError(t, NewWriter(&out).WriteStatusLine(code))
+		assert.Equal(t, line, out.String())
+	}
+
+	// Test: Unregistered codes keep the space before an empty phrase
+	var out bytes.Buffer
+	require.NoError(t, NewWriter(&out).WriteStatusLine(299))
+	assert.Equal(t, "HTTP/1.1 299 \r\n", out.String())
+
+	// Test: Custom reason phrase
+	out.Reset()
+	require.NoError(t, NewWriter(&out).WriteStatusLineReason(StatusOK, "All Good"))
+	assert.Equal(t, "HTTP/1.1 200 All Good\r\n", out.String())
+
+	// Test: Codes must have three digits, phrases no control characters
+	for _, code := range []StatusCode{0, 99, 1000, -200} {
+		out.Reset()
+		assert.Error(t, NewWriter(&out).WriteStatusLine(code), code)
+		assert.Zero(t, out.Len())
+	}
+	out.Reset()
+	assert.Error(t, NewWriter(&out).WriteStatusLineReason(StatusOK, "OK\r\nSet-Cookie: a=1"))
+	assert.Zero(t, out.Len())
+}
+
+func TestBodylessStatus(t *testing.T) {
+	// Test: Interim responses are followed by the final one
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	require.NoError(t, w.WriteStatusLine(StatusEarlyHints))
+	h := headers.NewHeaders()
+	h.Set("Link", "</style.css>; rel=preload")
+	require.NoError(t, w.WriteHeaders(h))
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\nLink: </style.css>; rel=preload\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\nContent-Length: 0\r\nContent-Type: text/plain\r\n\r\n", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: HTTP/1.0 clients never see interim responses
+	out.Reset()
+	w = NewWriter(&out)
+	w.SetVersion("1.0")
+	require.NoError(t, w.WriteStatusLine(StatusContinue))
+	require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+	assert.Zero(t, out.Len())
+	require.NoError(t, w.WriteHeader(StatusEarlyHints))
+	assert.Zero(t, out.Len())
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasPrefix(out.String(), "HTTP/1.0 200 OK\r\n"), out.String())
+
+	// Test: 204 and 304 need no framing and refuse a body
+	for _, code := range []StatusCode{StatusNoContent, StatusNotModified} {
+		out.Reset()
+		w = NewWriter(&out)
+		require.NoError(t, w.WriteStatusLine(code))
+		require.NoError(t, w.WriteHeaders(headers.NewHeaders()))
+		assert.True(t, w.KeepAlive())
+		_, err := w.WriteBody([]byte("x"))
+		assert.Error(t, err)
+	}
+}
+
+func TestHeaderAPI(t *testing.T) {
+	// Test: Header can change until the first Write, status defaults to 200
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().SetContentLength(5)
+	assert.False(t, w.Started())
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	assert.True(t, w.Started())
+	w.Header().Set("X-Late", "1")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: WriteHeader is lazy and only counts once
+	out.Reset()
+	w = NewWriter(&out)
+	assert.Equal(t, StatusOK, w.Status())
+	require.NoError(t, w.WriteHeader(StatusCreated))
+	assert.Error(t, w.WriteHeader(StatusOK))
+	assert.Equal(t, StatusCreated, w.Status())
+	assert.Zero(t, out.Len())
+	w.Header().Set("Location", "/items/1")
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 201 Created\r\nLocation: /items/1\r\nContent-Length: 0\r\n\r\n", out.String())
+
+	// Test: Interim responses are sent right away
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().Set("Link", "</a.css>; rel=preload")
+	require.NoError(t, w.WriteHeader(StatusEarlyHints))
+	assert.Equal(t, "HTTP/1.1 103 Early Hints\r\nLink: </a.css>; rel=preload\r\n\r\n", out.String())
+	require.NoError(t, w.WriteHeader(StatusNoContent))
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(out.String(), "\r\n\r\nHTTP/1.1 204 No Content\r\nLink: </a.css>; rel=preload\r\n\r\n"))
+
+	// Test: A chunked Header frames the writes and Finish ends the body
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().Set("Transfer-Encoding", "chunked")
+	_, err = w.Write([]byte("ab"))
+	require.NoError(t, err)
+	_, err = w.Write(nil)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("c"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nab\r\n1\r\nc\r\n0\r\n\r\n", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: An aborted response is left unterminated
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().Set("Transfer-Encoding", "chunked")
+	_, err = w.Write([]byte("ab"))
+	require.NoError(t, err)
+	w.Abort()
+	require.NoError(t, w.Finish())
+	assert.False(t, strings.HasSuffix(out.String(), "0\r\n\r\n"))
+	assert.False(t, w.KeepAlive())
+}
+
+func TestAutoFraming(t *testing.T) {
+	// Test: Small bodies get a Content-Length
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	_, err := w.Write([]byte("hello "))
+	require.NoError(t, err)
+	_, err = w.Write([]byte("world"))
+	require.NoError(t, err)
+	assert.Zero(t, out.Len())
+	assert.Equal(t, StatusOK, w.Status())
+	assert.Equal(t, 11, w.BytesWritten())
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 11\r\n\r\nhello world", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Overflowing the buffer switches to chunked
+	out.Reset()
+	w = NewWriter(&out)
+	big := bytes.Repeat([]byte("x"), bufferSize)
+	_, err = w.Write(big)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("y"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n"+
+		"1000\r\n"+string(big)+"\r\n1\r\ny\r\n0\r\n\r\n", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Flush sends the header and switches to chunked
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().Set("Trailer", "X-Sum")
+	_, err = w.Write([]byte("ab"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTrailer: X-Sum\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nab\r\n", out.String())
+	w.Trailer().Set("X-Sum", "3")
+	require.NoError(t, w.Finish())
+	assert.True(t, strings.HasSuffix(out.String(), "2\r\nab\r\n0\r\nX-Sum: 3\r\n\r\n"))
+
+	// Test: HTTP/1.0 clients get the overflow raw, ended by closing
+	out.Reset()
+	w = NewWriter(&out)
+	w.SetVersion("1.0")
+	_, err = w.Write(big)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.0 200 OK\r\nConnection: close\r\n\r\n"+string(big), out.String())
+	assert.False(t, w.KeepAlive())
+
+	// Test: Writing past a declared Content-Length aborts
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().SetContentLength(3)
+	_, err = w.Write([]byte("abcd"))
+	assert.ErrorIs(t, err, ErrContentLength)
+	require.NoError(t, w.Finish())
+	assert.False(t, w.KeepAlive())
+
+	// Test: Writing less than a declared Content-Length aborts
+	out.Reset()
+	w = NewWriter(&out)
+	w.Header().SetContentLength(3)
+	_, err = w.Write([]byte("ab"))
+	require.NoError(t, err)
+	assert.ErrorIs(t, w.Finish(), ErrContentLength)
+	assert.False(t, w.KeepAlive())
+
+	// Test: Replacing a response that has not started drops the buffer
+	out.Reset()
+	w = NewWriter(&out)
+	_, err = w.Write([]byte("partial"))
+	require.NoError(t, err)
+	assert.False(t, w.Started())
+	require.NoError(t, w.WriteStatusLine(StatusInternalServerError))
+	require.NoError(t, w.WriteHeaders(GetDefaultHeaders(0)))
+	require.NoError(t, w.Finish())
+	assert.NotContains(t, out.String(), "partial")
+	assert.Zero(t, w.BytesWritten())
+}
+
+func TestHeadResponse(t *testing.T) {
+	// Test: The computed Content-Length is sent without the body
+	var out bytes.Buffer
+	w := NewWriter(&out)
+	w.SetMethod("HEAD")
+	_, err := w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Finish())
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\n", out.String())
+	assert.True(t, w.KeepAlive())
+
+	// Test: Low level and chunked bodies are dropped too
+	out.Reset()
+	w = NewWriter(&out)
+	w.SetMethod("HEAD")
+	require.NoError(t, w.WriteStatusLine(StatusOK))
+	h := headers.NewHeaders()
+	h.Set("Transfer-Encoding", "chunked")
+	require.NoError(t, w.WriteHeaders(h))
+	_, err = w.WriteChunkedBody([]byte("hello"))
+	require.NoError(t, err)
+	_, err = w.WriteChunkedBodyDone()
+	require.NoError(t, err)
+	require.NoError(t, w.WriteTrailers(headers.NewHeaders()))
+	assert.Equal(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n", out.String())
+	assert.True(t, w.KeepAlive())
+}
@@ -0,0 +1,281 @@
+package router
+
+import (
+	"fmt"
+	"go-http/internal/request"
+	"go-http/internal/response"
+	"go-http/internal/server"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type segmentKind int
+
+const (
+	segmentLiteral segmentKind = iota
+	segmentParam
+	segmentWildcard
+)
+
+type segment struct {
+	kind  segmentKind
+	value string // literal text, or the name of the param/wildcard
+}
+
+type route struct {
+	method   string // empty matches any method
+	segments []segment
+	handler  server.Handler
+}
+
+// Router dispatches requests to handlers registered by method and path
+// pattern. Patterns look like "GET /users/{id}" or "/static/{path...}"; a
+// pattern without a method matches every method. Captured segments are
+// available through request.Request.PathValue.
+type Router struct {
+	routes     []*route
+	middleware []server.Middleware
+
+	// chain is dispatch wrapped in middleware, built on the first request.
+	chain     server.Handler
+	chainOnce sync.Once
+
+	// NotFound answers requests no route matches. Defaults to a plain 404.
+	NotFound server.Handler
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Use adds middleware that wraps every request the router serves, including
+// 404 and 405 answers. Each middleware is applied once, when the first
+// request is served, so Use has to be called before that.
+func (rt *Router) Use(middleware ...server.Middleware) {
+	rt.middleware = append(rt.middleware, middleware...)
+}
+
+// Handle registers h for pattern, wrapped in the given route specific
+// middleware. It panics if the pattern is malformed or already registered,
+// since that is a programming error.
+func (rt *Router) Handle(pattern string, h server.Handler, middleware ...server.Middleware) {
+	r, err := parsePattern(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("router: %v", err))
+	}
+	for _, existing := range rt.routes {
+		if existing.method == r.method && slices.Equal(existing.segments, r.segments) {
+			panic(fmt.Sprintf("router: pattern %q registered twice", pattern))
+		}
+	}
+	r.handler = server.Chain(h, middleware...)
+	rt.routes = append(rt.routes, r)
+}
+
+// Serve is a server.Handler that routes the request. When the path matches
+// but the method does not, it answers 405 with an Allow header listing the
+// methods that would have matched.
+func (rt *Router) Serve(w *response.Writer, req *request.Request) {
+	rt.chainOnce.Do(func() {
+		rt.chain = server.Chain(rt.dispatch, rt.middleware...)
+	})
+	rt.chain(w, req)
+}
+
+func (rt *Router) dispatch(w *response.Writer, req *request.Request) {
+	var best *route
+	var bestValues map[string]string
+	allowed := make([]string, 0)
+	// Asterisk- and authority-form targets have no path to route on.
+	routes := rt.routes
+	if req.URL.RawPath == "" {
+		routes = nil
+	}
+	parts := splitPath(req.URL.RawPath)
+	for _, r := range routes {
+		values, ok := r.match(parts)
+		if !ok {
+			continue
+		}
+		if r.methodRank(req.RequestLine.Method) < 0 {
+			allowed = append(allowed, r.method)
+			if r.method == "GET" {
+				allowed = append(allowed, "HEAD")
+			}
+			continue
+		}
+		if best == nil || r.moreSpecific(best, req.RequestLine.Method) {
+			best, bestValues = r, values
+		}
+	}
+
+	if best != nil {
+		for name, value := range bestValues {
+			req.SetPathValue(name, value)
+		}
+		best.handler(w, req)
+		return
+	}
+	if len(allowed) > 0 {
+		slices.Sort(allowed)
+		allowed = slices.Compact(allowed)
+		writeText(w, response.StatusMethodNotAllowed, "Method Not Allowed", strings.Join(allowed, ", "))
+		return
+	}
+	if rt.NotFound != nil {
+		rt.NotFound(w, req)
+		return
+	}
+	writeText(w, response.StatusNotFound, "Not Found", "")
+}
+
+func parsePattern(pattern string) (*route, error) {
+	r := &route{}
+	path := pattern
+	if method, rest, found := strings.Cut(pattern, " "); found {
+		r.method = method
+		path = strings.TrimLeft(rest, " ")
+		for _, c := range method {
+			if c < 'A' || c > 'Z' {
+				return nil, fmt.Errorf("invalid method in pattern %q", pattern)
+			}
+		}
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("pattern %q must start with /", pattern)
+	}
+
+	names := make(map[string]bool)
+	parts := splitPath(path)
+	for i, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("bad wildcard segment %q in pattern %q", part, pattern)
+			}
+			r.segments = append(r.segments, segment{kind: segmentLiteral, value: part})
+			continue
+		}
+
+		name := part[1 : len(part)-1]
+		kind := segmentParam
+		if trimmed, ok := strings.CutSuffix(name, "..."); ok {
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("%q must be the last segment in pattern %q", part, pattern)
+			}
+			name, kind = trimmed, segmentWildcard
+		}
+		if name == "" {
+			return nil, fmt.Errorf("empty wildcard name in pattern %q", pattern)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate wildcard %q in pattern %q", name, pattern)
+		}
+		names[name] = true
+		r.segments = append(r.segments, segment{kind: kind, value: name})
+	}
+	return r, nil
+}
+
+// splitPath splits "/a/b/" into ["a", "b", ""], keeping a trailing empty
+// segment so "/a/" and "/a" stay distinct. Segments are split on the escaped
+// path and then decoded, so an encoded "/" stays within its segment.
+func splitPath(rawPath string) []string {
+	parts := strings.Split(strings.TrimPrefix(rawPath, "/"), "/")
+	for i, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			parts[i] = unescaped
+		}
+	}
+	return parts
+}
+
+// match reports whether the route matches the path segments and returns the
+// captured values.
+func (r *route) match(parts []string) (map[string]string, bool) {
+	values := make(map[string]string)
+	for i, seg := range r.segments {
+		if seg.kind == segmentWildcard {
+			value := strings.Join(parts[i:], "/")
+			if hasDotSegment(value) {
+				return nil, false
+			}
+			values[seg.value] = value
+			return values, true
+		}
+		if i >= len(parts) {
+			return nil, false
+		}
+		switch seg.kind {
+		case segmentLiteral:
+			if parts[i] != seg.value {
+				return nil, false
+			}
+		case segmentParam:
+			if parts[i] == "" || hasDotSegment(parts[i]) {
+				return nil, false
+			}
+			values[seg.value] = parts[i]
+		}
+	}
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+	return values, true
+}
+
+// hasDotSegment reports whether a captured value has a "." or ".." segment.
+// The path itself has none left, but a decoded "%2F" can bring them back,
+// and a handler using the value as a file path must not be walked up the
+// tree.
+func hasDotSegment(value string) bool {
+	for part := range strings.SplitSeq(value, "/") {
+		if part == "." || part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// moreSpecific reports whether r should win over other when both match. The
+// first segment that differs decides, literals beating params beating
+// wildcards. Then a route naming the request method beats a GET route
+// answering HEAD, which beats one that matches any method.
+func (r *route) moreSpecific(other *route, method string) bool {
+	for i := 0; i < len(r.segments) && i < len(other.segments); i++ {
+		if r.segments[i].kind != other.segments[i].kind {
+			return r.segments[i].kind < other.segments[i].kind
+		}
+	}
+	if len(r.segments) != len(other.segments) {
+		return len(r.segments) > len(other.segments)
+	}
+	return r.methodRank(method) > other.methodRank(method)
+}
+
+// methodRank reports how well the route's method fits the request method:
+// 2 for the same method, 1 for a GET route answering HEAD, 0 for a route
+// matching any method and -1 for no match. Servers that support GET must
+// support HEAD, RFC 9110 9.3.2, and the writer drops the body for it.
+func (r *route) methodRank(method string) int {
+	switch {
+	case r.method == method:
+		return 2
+	case r.method == "GET" && method == "HEAD":
+		return 1
+	case r.method == "":
+		return 0
+	}
+	return -1
+}
+
+func writeText(w *response.Writer, statusCode response.StatusCode, body, allow string) {
+	w.WriteStatusLine(statusCode)
+	h := response.GetDefaultHeaders(len(body))
+	if allow != "" {
+		h.Set("Allow", allow)
+	}
+	w.WriteHeaders(h)
+	w.WriteBody([]byte(body))
+}
@@ -0,0 +1,169 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"go-http/internal/request"
+	"go-http/internal/response"
+	"go-http/internal/server"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// named returns a handler that answers with its name and the captured values.
+func named(name string, params ...string) func(w *response.Writer, req *request.Request) {
+	return func(w *response.Writer, req *request.Request) {
+		body := name
+		for _, p := range params {
+			body += " " + p + "=" + req.PathValue(p)
+		}
+		w.WriteStatusLine(response.StatusOK)
+		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+		w.WriteBody([]byte(body))
+	}
+}
+
+func serve(t *testing.T, rt *Router, method, target string) (*http.Response, string) {
+	t.Helper()
+	req, err := request.RequestFromReader(bytes.NewBufferString(
+		method + " " + target + " HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	rt.Serve(response.NewWriter(&out), req)
+
+	resp, err := http.ReadResponse(bufio.NewReader(&out), nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestRouter(t *testing.T) {
+	rt := New()
+	rt.Handle("GET /users/{id}", named("get-user", "id"))
+	rt.Handle("DELETE /users/{id}", named("delete-user", "id"))
+	rt.Handle("GET /users/me", named("me"))
+	rt.Handle("/static/{path...}", named("static", "path"))
+	rt.Handle("GET /", named("root"))
+
+	// Test: Method and param match
+	_, body := serve(t, rt, "GET", "/users/42")
+	assert.Equal(t, "get-user id=42", body)
+	_, body = serve(t, rt, "DELETE", "/users/42?force=1")
+	assert.Equal(t, "delete-user id=42", body)
+
+	// Test: Literal segment wins over a param
+	_, body = serve(t, rt, "GET", "/users/me")
+	assert.Equal(t, "me", body)
+
+	// Test: Trailing wildcard captures the rest of the path for any method
+	_, body = serve(t, rt, "POST", "/static/css/site.css")
+	assert.Equal(t, "static path=css/site.css", body)
+	_, body = serve(t, rt, "GET", "/static/")
+	assert.Equal(t, "static path=", body)
+
+	// Test: Exact root
+	_, body = serve(t, rt, "GET", "/")
+	assert.Equal(t, "root", body)
+
+	// Test: Paths are decoded and normalized before matching
+	_, body = serve(t, rt, "GET", "/users/a%20b")
+	assert.Equal(t, "get-user id=a b", body)
+	_, body = serve(t, rt, "GET", "/users/%2e%2e/users/7")
+	assert.Equal(t, "get-user id=7", body)
+	_, body = serve(t, rt, "GET", "/users/a%2Fb")
+	assert.Equal(t, "get-user id=a/b", body)
+	_, body = serve(t, rt, "GET", "http://example.com/users/me")
+	assert.Equal(t, "me", body)
+
+	// Test: Encoded slashes cannot smuggle dot segments into captures
+	resp, _ := serve(t, rt, "GET", "/static/..%2F..%2Fetc/passwd")
+	assert.Equal(t, 404, resp.StatusCode)
+	resp, _ = serve(t, rt, "GET", "/users/..%2F..")
+	assert.Equal(t, 404, resp.StatusCode)
+	_, body = serve(t, rt, "GET", "/static/a..b/c")
+	assert.Equal(t, "static path=a..b/c", body)
+
+	// Test: Unknown path is a 404
+	resp, _ = serve(t, rt, "GET", "/nope")
+	assert.Equal(t, 404, resp.StatusCode)
+	resp, _ = serve(t, rt, "GET", "/users/42/extra")
+	assert.Equal(t, 404, resp.StatusCode)
+
+	// Test: Known path with the wrong method is a 405 with Allow
+	resp, _ = serve(t, rt, "PUT", "/users/42")
+	assert.Equal(t, 405, resp.StatusCode)
+	assert.Equal(t, "DELETE, GET, HEAD", resp.Header.Get("Allow"))
+
+	// Test: GET routes answer HEAD unless a HEAD route is registered
+	resp, body = serve(t, rt, "HEAD", "/users/42")
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "get-user id=42", body)
+	rt.Handle("HEAD /users/{id}", named("head-user", "id"))
+	_, body = serve(t, rt, "HEAD", "/users/42")
+	assert.Equal(t, "head-user id=42", body)
+
+	// Test: Custom not found handler
+	rt.NotFound = named("custom-404")
+	_, body = serve(t, rt, "GET", "/nope")
+	assert.Equal(t, "custom-404", body)
+}
+
+func TestBadPatterns(t *testing.T) {
+	rt := New()
+	rt.Handle("GET /a/{id}", named("a"))
+	assert.Panics(t, func() { rt.Handle("GET /a/{id}", named("a")) })
+	assert.Panics(t, func() { rt.Handle("no-slash", named("x")) })
+	assert.Panics(t, func() { rt.Handle("/{rest...}/more", named("x")) })
+	assert.Panics(t, func() { rt.Handle("/{id}/{id}", named("x")) })
+	assert.Panics(t, func() { rt.Handle("/{}", named("x")) })
+	assert.Panics(t, func() { rt.Handle("get /", named("x")) })
+}
+
+func TestMiddleware(t *testing.T) {
+	var seen []string
+	tag := func(name string) server.Middleware {
+		return func(next server.Handler) server.Handler {
+			return func(w *response.Writer, req *request.Request) {
+				seen = append(seen, name)
+				next(w, req)
+			}
+		}
+	}
+
+	built := 0
+	counting := func(next server.Handler) server.Handler {
+		built++
+		return next
+	}
+
+	rt := New()
+	rt.Use(tag("global"), counting)
+	rt.Handle("GET /admin", named("admin"), tag("auth"))
+	rt.Handle("GET /public", named("public"))
+
+	// Test: Route middleware runs inside global middleware
+	_, body := serve(t, rt, "GET", "/admin")
+	assert.Equal(t, "admin", body)
+	assert.Equal(t, []string{"global", "auth"}, seen)
+
+	// Test: Route middleware only applies to its route
+	seen = nil
+	serve(t, rt, "GET", "/public")
+	assert.Equal(t, []string{"global"}, seen)
+
+	// Test: Global middleware also sees unmatched requests
+	seen = nil
+	resp, _ := serve(t, rt, "GET", "/missing")
+	assert.Equal(t, 404, resp.StatusCode)
+	assert.Equal(t, []string{"global"}, seen)
+
+	// Test: Global middleware is built once, not per request
+	assert.Equal(t, 1, built)
+}
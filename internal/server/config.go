@@ -0,0 +1,70 @@
+package server
+
+import (
+	"go-http/internal/request"
+	"time"
+)
+
+// Config holds the tunables of a Server. A zero value for any field disables
+// the corresponding limit.
+type Config struct {
+	// ReadHeaderTimeout bounds reading the request line and headers. If zero,
+	// ReadTimeout is used.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading an entire request, body included.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing the response, starting once the request has
+	// been read.
+	WriteTimeout time.Duration
+	// IdleTimeout bounds how long a keep-alive connection waits for its next
+	// request. If zero, ReadTimeout is used.
+	IdleTimeout time.Duration
+	// MaxRequestsPerConn caps how many requests are served on one persistent
+	// connection before it is closed.
+	MaxRequestsPerConn int
+	// Limits bounds the size of each request.
+	Limits request.Limits
+	// ErrorRenderer writes the responses for malformed requests and handler
+	// panics. If nil, DefaultErrorRenderer is used. Errors returned through
+	// HandleErrors are not covered; use HandleErrorsWith with this renderer.
+	ErrorRenderer ErrorRenderer
+	// UnfoldObsFold accepts header fields continued with obsolete line
+	// folding instead of rejecting the request. Only enable it for old
+	// clients that need it.
+	UnfoldObsFold bool
+}
+
+// DefaultConfig returns the configuration used by Serve.
+func DefaultConfig() Config {
+	return Config{
+		ReadHeaderTimeout:  10 * time.Second,
+		ReadTimeout:        30 * time.Second,
+		WriteTimeout:       30 * time.Second,
+		IdleTimeout:        120 * time.Second,
+		MaxRequestsPerConn: 100,
+		Limits:             request.DefaultLimits(),
+	}
+}
+
+func (c Config) headerTimeout() time.Duration {
+	if c.ReadHeaderTimeout > 0 {
+		return c.ReadHeaderTimeout
+	}
+	return c.ReadTimeout
+}
+
+func (c Config) idleTimeout() time.Duration {
+	if c.IdleTimeout > 0 {
+		return c.IdleTimeout
+	}
+	return c.ReadTimeout
+}
+
+// deadline turns a timeout into an absolute deadline, or the zero time (no
+// deadline) if the timeout is disabled.
+func deadline(start time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return start.Add(d)
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"errors"
+	"go-http/internal/request"
+	"go-http/internal/response"
+	"log"
+)
+
+// Error is an error that carries the HTTP status a handler wants to answer
+// with. Message is sent to the client as the response body.
+type Error struct {
+	StatusCode response.StatusCode
+	Message    string
+}
+
+func NewError(statusCode response.StatusCode, message string) *Error {
+	return &Error{StatusCode: statusCode, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorRenderer writes the response for an error. It is only called before
+// the response has started.
+type ErrorRenderer func(w *response.Writer, err *Error)
+
+// DefaultErrorRenderer sends the error message as a plain text body.
+func DefaultErrorRenderer(w *response.Writer, err *Error) {
+	w.WriteStatusLine(err.StatusCode)
+	body := []byte(err.Message)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+// ErrorHandler is a handler that can bail out by returning an error instead
+// of writing an error response itself.
+type ErrorHandler func(w *response.Writer, req *request.Request) error
+
+// HandleErrors adapts an ErrorHandler to a Handler, rendering errors with
+// DefaultErrorRenderer. It does not know the server it runs in, so
+// Config.ErrorRenderer is not used; pass the same renderer to
+// HandleErrorsWith to give handler errors the same pages as parse errors
+// and panics.
+func HandleErrors(h ErrorHandler) Handler {
+	return HandleErrorsWith(DefaultErrorRenderer, h)
+}
+
+// HandleErrorsWith adapts an ErrorHandler to a Handler. A returned *Error is
+// rendered with its status and message, a *request.Error (such as a body over
+// the size limit) with its status, and any other error becomes a 500 so
+// internal details are not leaked to the client; a *request.Error also closes
+// the connection. If the handler had already started its response the error
+// is only logged and the connection closed.
+func HandleErrorsWith(render ErrorRenderer, h ErrorHandler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		err := h(w, req)
+		if err == nil {
+			return
+		}
+
+		httpErr := toError(err)
+		if httpErr.StatusCode == response.StatusInternalServerError {
+			log.Printf("Handler error for %s %s: %v", req.RequestLine.Method, req.RequestLine.RequestTarget, err)
+		}
+		if w.Started() {
+			log.Printf("Handler error after response started: %v", err)
+			w.Abort()
+			return
+		}
+		// A request error leaves the body unreadable, so the connection
+		// cannot be reused and the client is told so.
+		var reqErr *request.Error
+		if errors.As(err, &reqErr) {
+			w.CloseAfterResponse()
+		}
+		w.Reset()
+		render(w, httpErr)
+	}
+}
+
+// toError converts err into an *Error whose message is safe to show to the
+// client.
+func toError(err error) *Error {
+	var httpErr *Error
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	var reqErr *request.Error
+	if errors.As(err, &reqErr) {
+		statusCode := response.StatusCode(reqErr.StatusCode)
+		return NewError(statusCode, response.StatusText(statusCode))
+	}
+	return NewError(response.StatusInternalServerError, response.StatusText(response.StatusInternalServerError))
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"testing"
+
+	"go-http/internal/request"
+	"go-http/internal/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPanicRecovery(t *testing.T) {
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		switch req.RequestLine.RequestTarget {
+		case "/panic":
+			panic("boom")
+		case "/panic-late":
+			w.WriteStatusLine(response.StatusOK)
+			panic("boom")
+		}
+		okHandler(w, req)
+	})
+
+	// Test: A panic before the response starts becomes a 500
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+	_, err := io.WriteString(conn, "GET /panic HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, "Internal Server Error", body)
+	assert.True(t, resp.Close)
+
+	// Test: A panic mid-response just closes the connection
+	conn = dial(t, s)
+	_, err = io.WriteString(conn, "GET /panic-late HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	_, err = io.ReadAll(conn)
+	require.NoError(t, err)
+
+	// Test: The server keeps serving other connections
+	conn = dial(t, s)
+	_, err = io.WriteString(conn, "GET /fine HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	_, body = readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, "/fine", body)
+}
+
+func TestHandleErrors(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.MaxBodyBytes = 4
+	s := startServerWithConfig(t, HandleErrors(func(w *response.Writer, req *request.Request) error {
+		switch req.RequestLine.RequestTarget {
+		case "/typed":
+			return NewError(response.StatusBadRequest, "missing name")
+		case "/untyped":
+			return errors.New("database password is hunter2")
+		case "/body":
+			_, err := req.BodyBytes()
+			return err
+		}
+		okHandler(w, req)
+		return nil
+	}), config)
+
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+
+	// Test: A typed error is rendered with its status and message
+	_, err := io.WriteString(conn, "GET /typed HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "missing name", body)
+
+	// Test: Other errors become a 500 without leaking the message
+	_, err = io.WriteString(conn, "GET /untyped HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, "Internal Server Error", body)
+
+	// Test: The connection stays usable after a rendered error
+	_, err = io.WriteString(conn, "GET /ok HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/ok", body)
+
+	// Test: Errors reading the body close the connection
+	for chunks, status := range map[string]int{
+		"zz\r\n":                  400,
+		"5\r\nhello\r\n0\r\n\r\n": 413,
+	} {
+		conn := dial(t, s)
+		_, err = io.WriteString(conn, "POST /body HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n"+chunks)
+		require.NoError(t, err)
+		resp, _ := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, status, resp.StatusCode)
+		assert.True(t, resp.Close)
+	}
+}
+
+func TestErrorRenderer(t *testing.T) {
+	config := DefaultConfig()
+	config.ErrorRenderer = func(w *response.Writer, err *Error) {
+		body := []byte("<h1>" + err.Message + "</h1>")
+		w.WriteStatusLine(err.StatusCode)
+		h := response.GetDefaultHeaders(len(body))
+		h.Set("Content-Type", "text/html")
+		w.WriteHeaders(h)
+		w.WriteBody(body)
+	}
+	s := startServerWithConfig(t, okHandler, config)
+
+	// Test: Parse errors go through the renderer without internal details
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nH@st: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "<h1>Bad Request</h1>", body)
+}
+
+func TestErrorRendererHeaderAPI(t *testing.T) {
+	config := DefaultConfig()
+	config.ErrorRenderer = func(w *response.Writer, err *Error) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(err.StatusCode)
+		io.WriteString(w, "<h1>"+err.Message+"</h1>")
+	}
+	s := startServerWithConfig(t, func(w *response.Writer, req *request.Request) {
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(response.StatusCreated)
+		panic("boom")
+	}, config)
+
+	// Test: The rendered response is sent after a panic, without what the
+	// handler had set up
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("X-Handler"))
+	assert.Equal(t, "<h1>Internal Server Error</h1>", body)
+
+	// Test: The rendered response is sent for parse errors
+	conn = dial(t, s)
+	_, err = io.WriteString(conn, "GARBAGE\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 400, resp.StatusCode)
+	assert.Equal(t, "text/html", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "<h1>Bad Request</h1>", body)
+}
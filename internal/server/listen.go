@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// unixPrefix marks an address passed to Listen as a unix socket path.
+const unixPrefix = "unix:"
+
+// Listen opens a listener for addr. Addresses of the form "unix:/path" open a
+// unix domain socket with mode 0660, anything else is a TCP bind address such
+// as ":8080", "127.0.0.1:8080" or "[::1]:8080".
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		return ListenUnix(path, 0o660, -1, -1)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// ListenAll opens a listener for every address. If one fails the listeners
+// opened so far are closed again.
+func ListenAll(addrs ...string) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := Listen(addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// ListenUnix opens a unix domain socket at path, replacing a stale socket
+// left behind by a previous run. A socket another process is still serving
+// is left alone and an error returned. The socket file gets the given mode
+// and, if uid or gid is not -1, that ownership. The file is removed when the
+// listener is closed.
+func ListenUnix(path string, mode os.FileMode, uid, gid int) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		// Only a socket nobody answers on is stale; taking over one that is
+		// still served would silently cut off the running instance.
+		conn, err := net.Dial("unix", path)
+		if err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, err
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeUnixAndTCP(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "http.sock")
+	listeners, err := ListenAll("unix:"+socket, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	info, err := os.Stat(socket)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+
+	s := New(okHandler, DefaultConfig())
+	done := make(chan error, 1)
+	go func() { done <- s.ServeAll(listeners...) }()
+
+	// Test: The same handler answers on every listener
+	for _, l := range listeners {
+		conn, err := net.Dial(l.Addr().Network(), l.Addr().String())
+		require.NoError(t, err)
+		_, err = io.WriteString(conn, "GET /"+l.Addr().Network()+" HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		require.NoError(t, err)
+		_, body := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, "/"+l.Addr().Network(), body)
+		conn.Close()
+	}
+
+	// Test: Closing the server stops every listener and removes the socket
+	require.NoError(t, s.Close())
+	assert.ErrorIs(t, <-done, ErrServerClosed)
+	_, err = os.Stat(socket)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "http.sock")
+
+	// Test: A socket file left behind by a crashed process is replaced
+	stale, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := ListenUnix(socket, 0o600, -1, -1)
+	require.NoError(t, err)
+	l.Close()
+
+	// Test: A socket still being served is not taken over
+	live, err := ListenUnix(socket, 0o600, -1, -1)
+	require.NoError(t, err)
+	defer live.Close()
+	_, err = ListenUnix(socket, 0o600, -1, -1)
+	require.Error(t, err)
+	_, err = os.Stat(socket)
+	require.NoError(t, err)
+	conn, err := net.Dial("unix", socket)
+	require.NoError(t, err)
+	conn.Close()
+
+	// Test: A regular file is never removed
+	file := filepath.Join(t.TempDir(), "not-a-socket")
+	require.NoError(t, os.WriteFile(file, nil, 0o600))
+	_, err = ListenUnix(file, 0o600, -1, -1)
+	require.Error(t, err)
+}
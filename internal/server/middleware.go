@@ -0,0 +1,31 @@
+package server
+
+import (
+	"go-http/internal/request"
+	"go-http/internal/response"
+	"log"
+	"time"
+)
+
+// Middleware wraps a Handler to run code before and after it.
+type Middleware func(Handler) Handler
+
+// Chain wraps h in middleware. The first middleware is the outermost, so it
+// sees the request first and the finished response last.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// LogRequests logs the method, target, status, body size and duration of
+// every request.
+func LogRequests(next Handler) Handler {
+	return func(w *response.Writer, req *request.Request) {
+		start := time.Now()
+		next(w, req)
+		log.Printf("%s %s %d %dB %s",
+			req.RequestLine.Method, req.RequestLine.RequestTarget, w.Status(), w.BytesWritten(), time.Since(start))
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"go-http/internal/request"
+	"go-http/internal/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingWriter counts the raw bytes written to the connection.
+type countingWriter struct {
+	w     io.Writer
+	count *int
+}
+
+func (cw countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	*cw.count += n
+	return n, err
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(w *response.Writer, req *request.Request) {
+				order = append(order, name+" before")
+				next(w, req)
+				order = append(order, name+" after")
+			}
+		}
+	}
+
+	var status response.StatusCode
+	var bodyBytes, rawBytes int
+	observe := func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			w.Wrap(func(out io.Writer) io.Writer { return countingWriter{w: out, count: &rawBytes} })
+			next(w, req)
+			status, bodyBytes = w.Status(), w.BytesWritten()
+		}
+	}
+
+	// The response is on the wire before the middleware returns, so wait
+	// for the chain to finish before looking at what it recorded.
+	done := make(chan struct{})
+	finished := func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			defer close(done)
+			next(w, req)
+		}
+	}
+
+	s := startServer(t, Chain(okHandler, finished, trace("outer"), trace("inner"), observe))
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "GET /chained HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	readResponse(t, bufio.NewReader(conn))
+	<-done
+
+	// Test: First middleware is the outermost
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, order)
+
+	// Test: Middleware observes status and sizes of the response
+	assert.Equal(t, response.StatusOK, status)
+	assert.Equal(t, len("/chained"), bodyBytes)
+	assert.Greater(t, rawBytes, bodyBytes)
+}
+
+func TestLogRequests(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	done := make(chan struct{})
+	s := startServer(t, Chain(func(w *response.Writer, req *request.Request) {}, func(next Handler) Handler {
+		return func(w *response.Writer, req *request.Request) {
+			defer close(done)
+			next(w, req)
+		}
+	}, LogRequests))
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "GET /empty HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, bufio.NewReader(conn))
+	<-done
+
+	// Test: A handler that writes nothing is logged with the 200 it gets
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, logs.String(), "GET /empty 200 0B")
+}
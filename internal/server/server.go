@@ -1,69 +1,385 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"go-http/internal/request"
 	"go-http/internal/response"
 	"log"
 	"net"
+	"runtime/debug"
+	"sync"
 	"sync/atomic"
+	"time"
 )
 
+// shutdownPollInterval is how often Shutdown checks for connections that
+// have finished their in-flight request.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// maxDiscardBytes is how much of a body the handler left unread is drained
+// to keep the connection alive. Larger leftovers close the connection.
+const maxDiscardBytes = 256 << 10
+
 type Handler func(w *response.Writer, req *request.Request)
 
+// ErrServerClosed is returned by Serve once the server has been closed.
+var ErrServerClosed = errors.New("server closed")
+
 type Server struct {
-	listener net.Listener
-	closed   atomic.Bool
-	handler  Handler
+	closed  atomic.Bool
+	handler Handler
+	config  Config
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[*conn]struct{}
+
+	// done is closed once the server starts closing down.
+	done     chan struct{}
+	doneOnce sync.Once
+}
+
+// conn is a tracked client connection. idle is true while the connection is
+// waiting for the next request and false while one is being served.
+type conn struct {
+	net.Conn
+	idle bool
+}
+
+// New creates a server that is not yet accepting connections. Hand it one or
+// more listeners with Serve.
+func New(handler Handler, config Config) *Server {
+	return &Server{
+		handler:   handler,
+		config:    config,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*conn]struct{}),
+		done:      make(chan struct{}),
+	}
 }
 
 func Serve(port int, handler Handler) (*Server, error) {
+	return ServeWithConfig(port, handler, DefaultConfig())
+}
+
+func ServeWithConfig(port int, handler Handler, config Config) (*Server, error) {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
 		return nil, err
 	}
-	s := &Server{
-		listener: listener,
-		handler:  handler,
+	s := New(handler, config)
+	s.trackListener(listener)
+	go s.serve(listener)
+	return s, nil
+}
+
+// ServeTLS is like ServeWithConfig but terminates TLS using the certificates
+// in certs. The store is watched for changes for as long as the server runs.
+func ServeTLS(port int, handler Handler, config Config, certs *CertStore) (*Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
 	}
-	go s.listen()
+	s := New(handler, config)
+	tlsListener := tls.NewListener(listener, certs.TLSConfig())
+	s.trackListener(tlsListener)
+	go certs.Watch(s.done)
+	go s.serve(tlsListener)
 	return s, nil
 }
 
-func (s *Server) Close() error {
-	s.closed.Store(true)
-	if s.listener != nil {
-		return s.listener.Close()
+// Serve accepts connections on l until the server is closed, then returns
+// ErrServerClosed. It may be called from several goroutines with different
+// listeners to serve the same handler on all of them.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l) {
+		l.Close()
+		return ErrServerClosed
 	}
-	return nil
+	return s.serve(l)
 }
 
-func (s *Server) listen() {
+// serve runs the accept loop for a listener already registered with
+// trackListener.
+func (s *Server) serve(l net.Listener) error {
+	defer s.untrackListener(l)
+
 	for {
-		conn, err := s.listener.Accept()
+		netConn, err := l.Accept()
 		if err != nil {
 			if s.closed.Load() {
-				return
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
 			}
 			log.Printf("Accept Error: %v", err)
 			continue
 		}
-		go s.handle(conn)
+		c := &conn{Conn: netConn, idle: true}
+		if !s.track(c) {
+			c.Close()
+			return ErrServerClosed
+		}
+		go s.handle(c)
 	}
 }
 
-func (s *Server) handle(conn net.Conn) {
-	defer conn.Close()
+// ServeTLS is like Serve but terminates TLS on l using the certificates in
+// certs, which are watched for changes while the server runs.
+func (s *Server) ServeTLS(l net.Listener, certs *CertStore) error {
+	go certs.Watch(s.done)
+	return s.Serve(tls.NewListener(l, certs.TLSConfig()))
+}
 
-	w := response.NewWriter(conn)
+// ServeAll serves on every listener at once and returns when all of them
+// have stopped, with the first error encountered.
+func (s *Server) ServeAll(listeners ...net.Listener) error {
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() { errs <- s.Serve(l) }()
+	}
+	var first error
+	for range listeners {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
 
-	req, err := request.RequestFromReader(conn)
-	if err != nil {
-		w.WriteStatusLine(response.StatusBadRequest)
-		body := []byte(fmt.Sprintf("Error parsing request: %v", err))
-		w.WriteHeaders(response.GetDefaultHeaders(len(body)))
-		w.WriteBody(body)
-		return
+// Addrs returns the addresses of the listeners currently being served.
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for l := range s.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// Close stops the listeners and immediately closes every connection,
+// including those with a request in flight. Use Shutdown to let them finish.
+func (s *Server) Close() error {
+	s.shutdown()
+	err := s.closeListeners()
+	s.closeConns(false)
+	return err
+}
+
+// Shutdown stops accepting new connections, closes idle ones and waits for
+// in-flight requests to complete. If ctx is done first the remaining
+// connections are closed and ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdown()
+	err := s.closeListeners()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if s.closeConns(true) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			s.closeConns(false)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// shutdown flags the server as closing and signals background goroutines.
+func (s *Server) shutdown() {
+	s.closed.Store(true)
+	s.doneOnce.Do(func() { close(s.done) })
+}
+
+// closeConns closes tracked connections, only the idle ones if idleOnly is
+// set. It reports whether no connections are left.
+func (s *Server) closeConns(idleOnly bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		if idleOnly && !c.idle {
+			continue
+		}
+		c.Close()
+		delete(s.conns, c)
 	}
+	return len(s.conns) == 0
+}
+
+// track registers c with the server. It returns false if the server is
+// already shutting down, in which case c must not be served.
+func (s *Server) track(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+}
+
+func (s *Server) trackListener(l net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+}
+
+// closeListeners closes every listener and returns the first error.
+func (s *Server) closeListeners() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for l := range s.listeners {
+		if closeErr := l.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(s.listeners, l)
+	}
+	return err
+}
+
+// setIdle updates the state of c. Marking a connection active fails once the
+// server is shutting down so no new request is started.
+func (s *Server) setIdle(c *conn, idle bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !idle && s.closed.Load() {
+		return false
+	}
+	c.idle = idle
+	return true
+}
+
+func (s *Server) handle(c *conn) {
+	defer s.untrack(c)
+	defer c.Close()
+
+	reader := request.NewReader(c)
+	reader.Limits = s.config.Limits
+	reader.UnfoldObsFold = s.config.UnfoldObsFold
+	for served := 1; ; served++ {
+		s.setIdle(c, true)
+		if served == 1 {
+			c.SetReadDeadline(deadline(time.Now(), s.config.headerTimeout()))
+		} else {
+			c.SetReadDeadline(deadline(time.Now(), s.config.idleTimeout()))
+		}
+		if err := reader.Wait(); err != nil {
+			return
+		}
+		if !s.setIdle(c, false) {
+			return
+		}
+
+		start := time.Now()
+		readDeadline := deadline(start, s.config.ReadTimeout)
+		headerDeadline := deadline(start, s.config.headerTimeout())
+		if !readDeadline.IsZero() && readDeadline.Before(headerDeadline) {
+			headerDeadline = readDeadline
+		}
+		c.SetReadDeadline(headerDeadline)
+
+		req, err := reader.ReadRequest()
+		if err != nil {
+			s.handleReadError(c, err)
+			return
+		}
+		// The body is read by the handler, so ReadTimeout stays in effect
+		// while it runs.
+		c.SetReadDeadline(readDeadline)
+		c.SetWriteDeadline(deadline(time.Now(), s.config.WriteTimeout))
+
+		w := response.NewWriter(c)
+		w.SetVersion(req.RequestLine.HttpVersion)
+		w.SetMethod(req.RequestLine.Method)
+		if !req.KeepAlive() || s.closed.Load() ||
+			(s.config.MaxRequestsPerConn > 0 && served >= s.config.MaxRequestsPerConn) {
+			w.CloseAfterResponse()
+		}
+		s.serveRequest(c, w, req)
+		if err := req.Cleanup(); err != nil {
+			log.Printf("Cleanup Error: %v", err)
+		}
+		if !w.KeepAlive() || s.closed.Load() {
+			return
+		}
+		if err := req.DiscardBody(maxDiscardBytes); err != nil {
+			return
+		}
+	}
+}
+
+// serveRequest runs the handler and finishes its response, recovering from a
+// panic so it only takes down its own connection. A 500 is sent if the
+// response had not started.
+func (s *Server) serveRequest(c *conn, w *response.Writer, req *request.Request) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic serving %s %s for %s: %v\n%s",
+				req.RequestLine.Method, req.RequestLine.RequestTarget, c.RemoteAddr(), r, debug.Stack())
+			if w.Started() {
+				w.Abort()
+				return
+			}
+			w.CloseAfterResponse()
+			s.renderError(w, NewError(response.StatusInternalServerError, "Internal Server Error"))
+		}
+	}()
 	s.handler(w, req)
+	if err := w.Finish(); err != nil {
+		log.Printf("Finish Error: %v", err)
+	}
+}
+
+// handleReadError answers a request that could not be read. The connection
+// is closed afterwards since its framing can no longer be trusted. I/O
+// failures other than a malformed request are not answered at all.
+func (s *Server) handleReadError(c *conn, err error) {
+	var reqErr *request.Error
+	if !errors.As(err, &reqErr) {
+		return
+	}
+
+	c.SetWriteDeadline(deadline(time.Now(), s.config.WriteTimeout))
+	w := response.NewWriter(c)
+	w.CloseAfterResponse()
+	s.renderError(w, toError(reqErr))
+}
+
+// renderError replaces a response that has not started with err and
+// finishes it, since the handler's own response will not be.
+func (s *Server) renderError(w *response.Writer, err *Error) {
+	w.Reset()
+	if s.config.ErrorRenderer != nil {
+		s.config.ErrorRenderer(w, err)
+	} else {
+		DefaultErrorRenderer(w, err)
+	}
+	if err := w.Finish(); err != nil {
+		log.Printf("Finish Error: %v", err)
+	}
 }
@@ -0,0 +1,487 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go-http/internal/headers"
+	"go-http/internal/request"
+	"go-http/internal/response"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func okHandler(w *response.Writer, req *request.Request) {
+	body := []byte(req.RequestLine.RequestTarget)
+	w.WriteStatusLine(response.StatusOK)
+	w.WriteHeaders(response.GetDefaultHeaders(len(body)))
+	w.WriteBody(body)
+}
+
+func startServer(t *testing.T, handler Handler) *Server {
+	t.Helper()
+	return startServerWithConfig(t, handler, DefaultConfig())
+}
+
+func startServerWithConfig(t *testing.T, handler Handler, config Config) *Server {
+	t.Helper()
+	s, err := ServeWithConfig(0, handler, config)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func dial(t *testing.T, s *Server) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", s.Addrs()[0].String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp, string(body)
+}
+
+func TestKeepAlive(t *testing.T) {
+	s := startServer(t, okHandler)
+
+	// Test: Two requests on the same connection
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+	_, err := io.WriteString(conn, "GET /one HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/one", body)
+	assert.False(t, resp.Close)
+
+	_, err = io.WriteString(conn, "GET /two HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/two", body)
+
+	// Test: Pipelined requests arriving in a single write
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+	_, err = io.WriteString(conn,
+		"POST /a HTTP/1.1\r\nHost: localhost\r\nContent-Length: 3\r\n\r\nabc"+
+			"GET /b HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/a", body)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/b", body)
+
+	// Test: Connection: close from the client closes after the response
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+	_, err = io.WriteString(conn, "GET /bye HTTP/1.1\r\nHost: localhost\r\nConnection: close\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, "/bye", body)
+	assert.True(t, resp.Close)
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMaxRequestsPerConn(t *testing.T) {
+	s := startServer(t, okHandler)
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+	maxRequestsPerConn := DefaultConfig().MaxRequestsPerConn
+	for i := 1; i <= maxRequestsPerConn; i++ {
+		_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+		require.NoError(t, err)
+		resp, _ := readResponse(t, r)
+		assert.Equal(t, i == maxRequestsPerConn, resp.Close)
+	}
+	_, err := r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		if req.RequestLine.RequestTarget == "/slow" {
+			close(started)
+			<-release
+		}
+		okHandler(w, req)
+	})
+
+	addr := s.Addrs()[0].String()
+
+	// An idle keep-alive connection that already served one request
+	idle := dial(t, s)
+	idleReader := bufio.NewReader(idle)
+	_, err := io.WriteString(idle, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	readResponse(t, idleReader)
+
+	busy := dial(t, s)
+	_, err = io.WriteString(busy, "GET /slow HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	<-started
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(context.Background()) }()
+
+	// Test: Idle connection is closed right away
+	_, err = idleReader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: New connections are refused
+	_, err = net.Dial("tcp", addr)
+	assert.Error(t, err)
+
+	// Test: In-flight request completes before Shutdown returns
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned with a request in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	_, body := readResponse(t, bufio.NewReader(busy))
+	assert.Equal(t, "/slow", body)
+	require.NoError(t, <-done)
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		close(started)
+		<-release
+	})
+
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	<-started
+
+	// Test: Stuck connections are force closed when the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = s.Shutdown(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestTimeouts(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadHeaderTimeout = 100 * time.Millisecond
+	config.IdleTimeout = 100 * time.Millisecond
+	s := startServerWithConfig(t, okHandler, config)
+
+	// Test: A silent client is disconnected without a response
+	conn := dial(t, s)
+	n, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, 0, n)
+	assert.ErrorIs(t, err, io.EOF)
+
+	// Test: Headers not finished in time get a 408
+	conn = dial(t, s)
+	r := bufio.NewReader(conn)
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: local")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, r)
+	assert.Equal(t, 408, resp.StatusCode)
+	assert.True(t, resp.Close)
+
+	// Test: Keep-alive connection is closed after the idle timeout
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+	_, err = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	readResponse(t, r)
+	_, err = r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestBodyTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = 100 * time.Millisecond
+	s := startServerWithConfig(t, HandleErrors(func(w *response.Writer, req *request.Request) error {
+		if _, err := io.ReadAll(req.Body); err != nil {
+			return err
+		}
+		okHandler(w, req)
+		return nil
+	}), config)
+
+	// Test: A body not sent in time gets a 408
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 10\r\n\r\nab")
+	require.NoError(t, err)
+	resp, _ := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 408, resp.StatusCode)
+	assert.True(t, resp.Close)
+}
+
+func TestLimitResponses(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.MaxRequestLineBytes = 64
+	config.Limits.MaxHeaderBytes = 128
+	config.Limits.MaxBodyBytes = 4
+	s := startServerWithConfig(t, okHandler, config)
+
+	tests := []struct {
+		request string
+		status  int
+	}{
+		{"GET /" + strings.Repeat("a", 100) + " HTTP/1.1\r\nHost: localhost\r\n\r\n", 414},
+		{"GET / HTTP/1.1\r\nHost: localhost\r\nX-Big: " + strings.Repeat("a", 200) + "\r\n\r\n", 431},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 5\r\n\r\nhello", 413},
+		{"GET / HTTP/1.1\r\nHost localhost\r\n\r\n", 400},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: gzip\r\n\r\n", 501},
+		{"POST / HTTP/1.1\r\nHost: localhost\r\nContent-Length: 1\r\nTransfer-Encoding: chunked\r\n\r\n", 400},
+		{"GET / HTTP/2.0\r\nHost: localhost\r\n\r\n", 505},
+	}
+	for _, tt := range tests {
+		conn := dial(t, s)
+		_, err := io.WriteString(conn, tt.request)
+		require.NoError(t, err)
+		resp, _ := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, tt.status, resp.StatusCode)
+		assert.True(t, resp.Close)
+	}
+}
+
+func TestBareLF(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.MaxRequestLineBytes = 64
+	s := startServerWithConfig(t, okHandler, config)
+
+	// Test: LF line endings get a 400 right away instead of a timeout
+	for _, request := range []string{
+		"GET / HTTP/1.1\nHost: localhost\n\n",
+		"GET / HTTP/1.1\r\nHost: localhost\n\n",
+		"GET /" + strings.Repeat("a", 40) + " HTTP/1.1\nHost: localhost\nX-Pad: " + strings.Repeat("a", 40) + "\n\n",
+	} {
+		conn := dial(t, s)
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, err := io.WriteString(conn, request)
+		require.NoError(t, err)
+		resp, _ := readResponse(t, bufio.NewReader(conn))
+		assert.Equal(t, 400, resp.StatusCode, request)
+		assert.True(t, resp.Close)
+	}
+}
+
+func TestHTTP10(t *testing.T) {
+	chunked := func(w *response.Writer, req *request.Request) {
+		w.WriteStatusLine(response.StatusOK)
+		h := response.GetDefaultHeaders(0)
+		h.Del("Content-Length")
+		h.Set("Transfer-Encoding", "chunked")
+		w.WriteHeaders(h)
+		w.WriteChunkedBody([]byte("hello "))
+		w.WriteChunkedBody([]byte("world"))
+		w.WriteChunkedBodyDone()
+		w.WriteTrailers(headers.NewHeaders())
+	}
+	mux := func(w *response.Writer, req *request.Request) {
+		switch req.RequestLine.RequestTarget {
+		case "/chunked":
+			chunked(w, req)
+			return
+		case "/nocontent":
+			w.WriteHeader(response.StatusNoContent)
+			return
+		case "/notmodified":
+			w.WriteHeader(response.StatusNotModified)
+			return
+		}
+		okHandler(w, req)
+	}
+	s := startServer(t, mux)
+
+	// Test: HTTP/1.0 gets a 1.0 status line and is closed by default
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+	_, err := io.WriteString(conn, "GET /old HTTP/1.0\r\n\r\n")
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/1.0 200 OK\r\n", line)
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(rest), "Connection: close\r\n")
+	assert.True(t, strings.HasSuffix(string(rest), "\r\n\r\n/old"))
+
+	// Test: Connection: keep-alive keeps a 1.0 connection open
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+	_, err = io.WriteString(conn, "GET /one HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, "/one", body)
+	assert.False(t, resp.Close)
+	_, err = io.WriteString(conn, "GET /two HTTP/1.0\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, "/two", body)
+	assert.True(t, resp.Close)
+
+	// Test: Bodyless 204 and 304 responses keep a 1.0 connection open
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+	_, err = io.WriteString(conn,
+		"GET /nocontent HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"+
+			"GET /notmodified HTTP/1.0\r\nConnection: keep-alive\r\n\r\n"+
+			"GET /after HTTP/1.0\r\n\r\n")
+	require.NoError(t, err)
+	for _, status := range []int{204, 304} {
+		resp, err := http.ReadResponse(r, nil)
+		require.NoError(t, err)
+		assert.Equal(t, status, resp.StatusCode)
+		assert.False(t, resp.Close)
+		resp.Body.Close()
+	}
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/after", body)
+
+	// Test: Chunked responses are sent raw to 1.0 clients, ended by close
+	conn = dial(t, s)
+	_, err = io.WriteString(conn, "GET /chunked HTTP/1.0\r\nConnection: keep-alive\r\n\r\n")
+	require.NoError(t, err)
+	raw, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.NotContains(t, strings.ToLower(string(raw)), "transfer-encoding")
+	assert.Contains(t, string(raw), "Connection: close\r\n")
+	assert.True(t, strings.HasSuffix(string(raw), "\r\n\r\nhello world"))
+}
+
+func TestHeaderAPI(t *testing.T) {
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		switch req.RequestLine.RequestTarget {
+		case "/empty":
+			return
+		case "/small":
+			io.WriteString(w, "small body")
+			return
+		case "/panic":
+			io.WriteString(w, "partial")
+			panic("boom")
+		case "/created":
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(response.StatusCreated)
+			return
+		}
+		w.Header().Set("Transfer-Encoding", "chunked")
+		io.WriteString(w, "hello ")
+		io.WriteString(w, "world")
+	})
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+
+	// Test: Writes are framed and finished, the connection stays open
+	_, err := io.WriteString(conn, "GET /stream HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "hello world", body)
+
+	// Test: A handler that only sets a status gets an empty body
+	_, err = io.WriteString(conn, "GET /created HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "/items/1", resp.Header.Get("Location"))
+	assert.Empty(t, body)
+
+	// Test: Small bodies get a Content-Length
+	_, err = io.WriteString(conn, "GET /small HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, int64(10), resp.ContentLength)
+	assert.Equal(t, "small body", body)
+
+	// Test: A panic before the buffer is sent still gets a clean 500
+	_, err = io.WriteString(conn, "GET /panic HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, "Internal Server Error", body)
+	conn = dial(t, s)
+	r = bufio.NewReader(conn)
+
+	// Test: A handler that writes nothing answers 200
+	_, err = io.WriteString(conn, "GET /empty HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body = readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Empty(t, body)
+	assert.False(t, resp.Close)
+}
+
+func TestHeadRequest(t *testing.T) {
+	s := startServer(t, func(w *response.Writer, req *request.Request) {
+		io.WriteString(w, "hello")
+	})
+	conn := dial(t, s)
+	r := bufio.NewReader(conn)
+
+	// Test: HEAD gets the Content-Length but no body, and the connection
+	// stays in sync for the next request
+	_, err := io.WriteString(conn, "HEAD /head HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, err := http.ReadResponse(r, &http.Request{Method: "HEAD"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), resp.ContentLength)
+	resp.Body.Close()
+	_, err = io.WriteString(conn, "GET /get HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "hello", body)
+}
+
+func TestMultipartCleanup(t *testing.T) {
+	config := DefaultConfig()
+	config.Limits.MaxMultipartMemory = 4
+	tmpfiles := make(chan string, 1)
+	s := startServerWithConfig(t, func(w *response.Writer, req *request.Request) {
+		form, err := req.MultipartForm()
+		require.NoError(t, err)
+		f, err := form.File["f"][0].Open()
+		require.NoError(t, err)
+		tmpfiles <- f.(*os.File).Name()
+		f.Close()
+		okHandler(w, req)
+	}, config)
+
+	// Test: Spooled files are removed once the handler returns
+	body := "--b\r\nContent-Disposition: form-data; name=\"f\"; filename=\"f\"\r\n\r\n0123456789\r\n--b--\r\n"
+	conn := dial(t, s)
+	_, err := io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: localhost\r\n"+
+		"Content-Type: multipart/form-data; boundary=b\r\nContent-Length: "+strconv.Itoa(len(body))+"\r\n\r\n"+body)
+	require.NoError(t, err)
+	resp, _ := readResponse(t, bufio.NewReader(conn))
+	assert.Equal(t, 200, resp.StatusCode)
+	tmpfile := <-tmpfiles
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(tmpfile)
+		return errors.Is(err, os.ErrNotExist)
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// certPollInterval is how often certificate files are checked for changes.
+const certPollInterval = 5 * time.Second
+
+// CertPair names a PEM encoded certificate chain and its private key on disk.
+type CertPair struct {
+	CertFile string
+	KeyFile  string
+}
+
+// CertStore holds the certificates served over TLS and picks one for each
+// handshake from the SNI server name. Reloading swaps in new certificates for
+// future handshakes without touching established connections.
+type CertStore struct {
+	pairs []CertPair
+
+	mu       sync.RWMutex
+	certs    []*tls.Certificate
+	byName   map[string]*tls.Certificate
+	modTimes map[string]time.Time
+}
+
+func NewCertStore(pairs ...CertPair) (*CertStore, error) {
+	if len(pairs) == 0 {
+		return nil, errors.New("no certificates given")
+	}
+	cs := &CertStore{pairs: pairs}
+	if err := cs.Reload(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Reload reads every certificate pair from disk again. On error the
+// previously loaded certificates are kept.
+func (cs *CertStore) Reload() error {
+	certs := make([]*tls.Certificate, 0, len(cs.pairs))
+	byName := make(map[string]*tls.Certificate)
+	modTimes := make(map[string]time.Time)
+
+	for _, pair := range cs.pairs {
+		cert, err := tls.LoadX509KeyPair(pair.CertFile, pair.KeyFile)
+		if err != nil {
+			return fmt.Errorf("loading %s: %w", pair.CertFile, err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", pair.CertFile, err)
+		}
+		cert.Leaf = leaf
+		certs = append(certs, &cert)
+
+		names := leaf.DNSNames
+		if len(names) == 0 && leaf.Subject.CommonName != "" {
+			names = []string{leaf.Subject.CommonName}
+		}
+		for _, name := range names {
+			name = strings.ToLower(name)
+			if _, ok := byName[name]; !ok {
+				byName[name] = &cert
+			}
+		}
+
+		for _, file := range []string{pair.CertFile, pair.KeyFile} {
+			if info, err := os.Stat(file); err == nil {
+				modTimes[file] = info.ModTime()
+			}
+		}
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.certs = certs
+	cs.byName = byName
+	cs.modTimes = modTimes
+	return nil
+}
+
+// GetCertificate selects a certificate for a handshake. It matches the SNI
+// name exactly, then against wildcard certificates, and otherwise falls back
+// to the first certificate.
+func (cs *CertStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if cert, ok := cs.byName[name]; ok {
+		return cert, nil
+	}
+	if _, rest, found := strings.Cut(name, "."); found {
+		if cert, ok := cs.byName["*."+rest]; ok {
+			return cert, nil
+		}
+	}
+	return cs.certs[0], nil
+}
+
+// changed reports whether any certificate or key file was modified since the
+// last reload.
+func (cs *CertStore) changed() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	for _, pair := range cs.pairs {
+		for _, file := range []string{pair.CertFile, pair.KeyFile} {
+			info, err := os.Stat(file)
+			if err != nil {
+				continue
+			}
+			if !info.ModTime().Equal(cs.modTimes[file]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Watch reloads the certificates on SIGHUP and whenever the files change on
+// disk, until stop is closed.
+func (cs *CertStore) Watch(stop <-chan struct{}) {
+	cs.watch(stop, certPollInterval)
+}
+
+// watch is Watch polling the files every interval.
+func (cs *CertStore) watch(stop <-chan struct{}, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+		case <-ticker.C:
+			if !cs.changed() {
+				continue
+			}
+		}
+		if err := cs.Reload(); err != nil {
+			log.Printf("Certificate reload error: %v", err)
+			continue
+		}
+		log.Println("Certificates reloaded")
+	}
+}
+
+// TLSConfig returns a server TLS configuration backed by the store.
+func (cs *CertStore) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cs.GetCertificate,
+		NextProtos:     []string{"http/1.1"},
+	}
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSignedCert generates a self-signed certificate for names and
+// writes it to dir, returning the file pair and the parsed certificate.
+func writeSelfSignedCert(t *testing.T, dir, prefix string, serial int64, names ...string) (CertPair, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: names[0]},
+		DNSNames:              names,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	pair := CertPair{
+		CertFile: filepath.Join(dir, prefix+".crt"),
+		KeyFile:  filepath.Join(dir, prefix+".key"),
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, os.WriteFile(pair.CertFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(pair.KeyFile, keyPEM, 0o600))
+	return pair, cert
+}
+
+func dialTLS(t *testing.T, s *Server, serverName string) *tls.Conn {
+	t.Helper()
+	conn, err := tls.Dial("tcp", s.Addrs()[0].String(), &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServeTLS(t *testing.T) {
+	dir := t.TempDir()
+	pairA, certA := writeSelfSignedCert(t, dir, "a", 1, "a.test")
+	pairB, certB := writeSelfSignedCert(t, dir, "b", 2, "*.b.test")
+
+	store, err := NewCertStore(pairA, pairB)
+	require.NoError(t, err)
+	s, err := ServeTLS(0, okHandler, DefaultConfig(), store)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	// Test: SNI selects the matching certificate
+	conn := dialTLS(t, s, "a.test")
+	assert.Equal(t, certA.SerialNumber, conn.ConnectionState().PeerCertificates[0].SerialNumber)
+	r := bufio.NewReader(conn)
+	_, err = io.WriteString(conn, "GET /secure HTTP/1.1\r\nHost: a.test\r\n\r\n")
+	require.NoError(t, err)
+	resp, body := readResponse(t, r)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "/secure", body)
+
+	// Test: Wildcard certificate matches a subdomain
+	connB := dialTLS(t, s, "www.b.test")
+	assert.Equal(t, certB.SerialNumber, connB.ConnectionState().PeerCertificates[0].SerialNumber)
+
+	// Test: Unknown names fall back to the first certificate
+	connC := dialTLS(t, s, "unknown.test")
+	assert.Equal(t, certA.SerialNumber, connC.ConnectionState().PeerCertificates[0].SerialNumber)
+
+	// Test: Reload serves the new certificate without dropping old conns
+	_, newCertA := writeSelfSignedCert(t, dir, "a", 3, "a.test")
+	require.NoError(t, store.Reload())
+	conn2 := dialTLS(t, s, "a.test")
+	assert.Equal(t, newCertA.SerialNumber, conn2.ConnectionState().PeerCertificates[0].SerialNumber)
+
+	_, err = io.WriteString(conn, "GET /still-here HTTP/1.1\r\nHost: a.test\r\n\r\n")
+	require.NoError(t, err)
+	_, body = readResponse(t, r)
+	assert.Equal(t, "/still-here", body)
+}
+
+func TestCertStoreReloadKeepsOldOnError(t *testing.T) {
+	dir := t.TempDir()
+	pair, cert := writeSelfSignedCert(t, dir, "a", 1, "a.test")
+	store, err := NewCertStore(pair)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(pair.CertFile, []byte("garbage"), 0o600))
+	assert.True(t, store.changed())
+	require.Error(t, store.Reload())
+
+	got, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.test"})
+	require.NoError(t, err)
+	assert.Equal(t, cert.SerialNumber, got.Leaf.SerialNumber)
+}
+
+// startWatch runs the store's watch loop until the test ends.
+func startWatch(t *testing.T, store *CertStore, interval time.Duration) {
+	t.Helper()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		store.watch(stop, interval)
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+}
+
+func servedSerial(t *testing.T, store *CertStore) int64 {
+	t.Helper()
+	got, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "a.test"})
+	require.NoError(t, err)
+	return got.Leaf.SerialNumber.Int64()
+}
+
+func TestCertStoreWatch(t *testing.T) {
+	dir := t.TempDir()
+	pair, _ := writeSelfSignedCert(t, dir, "a", 1, "a.test")
+	store, err := NewCertStore(pair)
+	require.NoError(t, err)
+	startWatch(t, store, 10*time.Millisecond)
+
+	// Test: Rewritten files are picked up by polling
+	writeSelfSignedCert(t, dir, "a", 2, "a.test")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(pair.CertFile, later, later))
+	assert.Eventually(t, func() bool { return servedSerial(t, store) == 2 }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestCertStoreWatchSIGHUP(t *testing.T) {
+	dir := t.TempDir()
+	pair, _ := writeSelfSignedCert(t, dir, "a", 1, "a.test")
+	store, err := NewCertStore(pair)
+	require.NoError(t, err)
+
+	// Catch SIGHUP here too, so one sent before the watch loop has
+	// registered for it does not kill the test process.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	t.Cleanup(func() { signal.Stop(hup) })
+	startWatch(t, store, time.Hour)
+
+	// Test: SIGHUP reloads without waiting for a poll
+	writeSelfSignedCert(t, dir, "a", 2, "a.test")
+	assert.Eventually(t, func() bool {
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+		return servedSerial(t, store) == 2
+	}, 2*time.Second, 20*time.Millisecond)
+}